@@ -160,6 +160,9 @@ func applyManifest(manifest *model.Manifest) error {
 		}
 		manifestStr := string(manifestBytes)
 
+		// Escape backticks, they would end the raw string literal
+		manifestStr = strings.ReplaceAll(manifestStr, "`", "` + \"`\" + `")
+
 		// write generated code to file by using Go file template.
 		if err := os.WriteFile(
 			"server/manifest.go",
@@ -183,6 +186,9 @@ func applyManifest(manifest *model.Manifest) error {
 		// Escape newlines
 		manifestStr = strings.ReplaceAll(manifestStr, `\n`, `\\n`)
 
+		// Escape backticks, they would end the template literal
+		manifestStr = strings.ReplaceAll(manifestStr, "`", "\\`")
+
 		// write generated code to file by using JS file template.
 		if err := os.WriteFile(
 			"webapp/src/manifest.ts",
@@ -4,6 +4,8 @@ package main
 type ActionContext struct {
 	SubscriptionURL string `json:"subscription_url"`
 	Action          string `json:"action"`
+	AlarmID         string `json:"alarm_id"`
+	SnoozeHours     int    `json:"snooze_hours"`
 }
 
 // Action type for decoding action buttons
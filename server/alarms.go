@@ -0,0 +1,438 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	alarmThreadPrefix = "alarmThread_"
+	alarmSnoozePrefix = "alarmSnooze_"
+	// alarmIDProp holds the alarm id on the post that starts an alarm thread.
+	alarmIDProp = "alarm_id"
+	// alarmThreadTTL is how long after its last transition an alarm keeps posting into the
+	// same thread.
+	alarmThreadTTL = 7 * 24 * time.Hour
+
+	currentStateFieldTitle = "Current State"
+	acknowledgedFieldTitle = "Acknowledged"
+	snoozedUntilFieldTitle = "Snoozed Until"
+	resolvedFieldTitle     = "Resolved"
+	alarmAcknowledgePath   = "/alarm/acknowledge"
+	alarmSnoozePath        = "/alarm/snooze"
+	alarmResolvePath       = "/alarm/resolve"
+	alarmActionTimeFormat  = time.RFC1123
+
+	alarmStateOK               = "OK"
+	alarmStateAlarm            = "ALARM"
+	alarmStateInsufficientData = "INSUFFICIENT_DATA"
+
+	colorGreen  = "#008000"
+	colorRed    = "#FF0000"
+	colorYellow = "#FFFF00"
+	colorOrange = "#FFA500"
+	colorBlue   = "#2389D7"
+)
+
+// snoozeDurations are the snooze options offered on alarm posts, in hours.
+var snoozeDurations = []int{1, 4, 24}
+
+// AlarmThread links the state transitions of a CloudWatch alarm in a channel to the post that
+// started the thread.
+type AlarmThread struct {
+	RootPostID     string
+	State          string
+	AcknowledgedBy string
+	AcknowledgedAt int64
+}
+
+// alarmID identifies an alarm by account, region and name within a channel.
+func alarmID(channelID string, messageNotification SNSMessageNotification) string {
+	sum := sha256.Sum256([]byte(messageNotification.AWSAccountID + "/" + messageNotification.Region + "/" + messageNotification.AlarmName + "/" + channelID))
+	return hex.EncodeToString(sum[:16])
+}
+
+func alarmThreadKey(channelID string, messageNotification SNSMessageNotification) string {
+	return alarmThreadPrefix + alarmID(channelID, messageNotification)
+}
+
+func alarmStateColor(state string) string {
+	switch state {
+	case alarmStateAlarm:
+		return colorRed
+	case alarmStateInsufficientData, "INSUFFICIENT":
+		return colorYellow
+	default:
+		return colorGreen
+	}
+}
+
+func (t *AlarmThread) rootPostID() string {
+	return t.RootPostID
+}
+
+// isAlarmPost reports whether the post starts a thread of the alarm. Posts from before the alarm
+// id was kept on them are matched against the current thread of the alarm.
+func (p *Plugin) isAlarmPost(post *model.Post, id string) bool {
+	if postAlarmID, ok := post.GetProp(alarmIDProp).(string); ok {
+		return postAlarmID == id
+	}
+	thread, err := p.getAlarmThread(alarmThreadPrefix + id)
+	return err == nil && thread != nil && thread.RootPostID == post.Id
+}
+
+func (p *Plugin) getAlarmThread(key string) (*AlarmThread, error) {
+	var thread AlarmThread
+	stored, err := p.getThread(key, &thread)
+	if err != nil || stored == nil {
+		return nil, err
+	}
+	return &thread, nil
+}
+
+func (p *Plugin) storeAlarmThread(key string, thread *AlarmThread) error {
+	b, err := json.Marshal(thread)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal alarm thread")
+	}
+	if appErr := p.API.KVSetWithExpiry(key, b, int64(alarmThreadTTL.Seconds())); appErr != nil {
+		return appErr
+	}
+	return nil
+}
+
+// sendAlarmNotification posts a CloudWatch alarm to each channel, threading it under the post of
+// the alarm's earlier transitions.
+func (p *Plugin) sendAlarmNotification(attachment model.SlackAttachment, messageNotification SNSMessageNotification, channels []*TeamChannel) {
+	for _, channel := range channels {
+		if err := p.postAlarm(attachment, messageNotification, channel); err != nil {
+			p.API.LogError("AWSSNS unable to post the CloudWatch alarm", "channel_id", channel.ChannelID, "alarm_name", messageNotification.AlarmName, "err", err.Error())
+		}
+	}
+}
+
+// postAlarm threads the transitions of an incident under the post of the ALARM that started it.
+// The thread ends when the alarm returns to OK, so the next ALARM starts a new one. Transitions
+// outside of an incident are posted on their own.
+func (p *Plugin) postAlarm(attachment model.SlackAttachment, messageNotification SNSMessageNotification, channel *TeamChannel) error {
+	id := alarmID(channel.ChannelID, messageNotification)
+	key := alarmThreadPrefix + id
+	state := messageNotification.NewStateValue
+
+	snoozed, appErr := p.API.KVGet(alarmSnoozePrefix + id)
+	if appErr != nil {
+		return appErr
+	}
+	if snoozed != nil {
+		p.API.LogDebug("AWSSNS alarm is snoozed", "channel_id", channel.ChannelID, "alarm_name", messageNotification.AlarmName)
+		return nil
+	}
+
+	err := p.inThread(key, &AlarmThread{},
+		func(root *model.Post, thread threadRecord) error {
+			return p.replyToAlarmThread(attachment, key, thread.(*AlarmThread), root, channel, state)
+		},
+		func(stored []byte) error {
+			if state != alarmStateAlarm {
+				_, err := p.createBotPost(channel, "", []*model.SlackAttachment{&attachment})
+				return err
+			}
+
+			rootAttachment := attachment
+			rootAttachment.Fields = append([]*model.SlackAttachmentField{{
+				Title: currentStateFieldTitle,
+				Value: state,
+				Short: true,
+			}}, attachment.Fields...)
+			rootAttachment.Actions = p.alarmActions(channel, id)
+			root := p.newBotPost(channel, "", []*model.SlackAttachment{&rootAttachment})
+			root.AddProp(alarmIDProp, id)
+
+			_, err := p.startThread(key, stored, root, alarmThreadTTL, func(rootPostID string) interface{} {
+				return &AlarmThread{RootPostID: rootPostID, State: state}
+			})
+			return err
+		},
+	)
+	if err == errThreadClaimed {
+		p.API.LogWarn("AWSSNS unable to find the alarm thread, posting the transition on its own", "alarm_name", messageNotification.AlarmName)
+		_, err = p.createBotPost(channel, "", []*model.SlackAttachment{&attachment})
+	}
+	return err
+}
+
+// replyToAlarmThread posts a transition in the thread of the incident and shows the new state on
+// its root post. Returning to OK ends the thread.
+func (p *Plugin) replyToAlarmThread(attachment model.SlackAttachment, key string, thread *AlarmThread, root *model.Post, channel *TeamChannel, state string) error {
+	if _, err := p.createBotPost(channel, root.Id, []*model.SlackAttachment{&attachment}); err != nil {
+		return err
+	}
+
+	if err := p.updateAlarmRootPost(root, state); err != nil {
+		p.API.LogWarn("AWSSNS unable to update the alarm thread", "post_id", root.Id, "err", err.Error())
+	}
+
+	if state == alarmStateOK {
+		if appErr := p.API.KVDelete(key); appErr != nil {
+			return appErr
+		}
+		return nil
+	}
+
+	thread.State = state
+	return p.storeAlarmThread(key, thread)
+}
+
+// updateAlarmRootPost shows the latest state of the alarm on the post that started the thread.
+func (p *Plugin) updateAlarmRootPost(root *model.Post, state string) error {
+	attachments := root.Attachments()
+	if len(attachments) == 0 {
+		return errors.New("alarm post has no attachment")
+	}
+
+	attachment := attachments[0]
+	attachment.Color = alarmStateColor(state)
+	setAttachmentField(attachment, currentStateFieldTitle, state)
+
+	model.ParseSlackAttachment(root, attachments)
+	if _, appErr := p.API.UpdatePost(root); appErr != nil {
+		return appErr
+	}
+	return nil
+}
+
+// setAttachmentField updates the value of the field with the given title, adding the field
+// after the existing ones when it is missing.
+func setAttachmentField(attachment *model.SlackAttachment, title, value string) {
+	for _, field := range attachment.Fields {
+		if field.Title == title {
+			field.Value = value
+			return
+		}
+	}
+	attachment.Fields = append(attachment.Fields, &model.SlackAttachmentField{
+		Title: title,
+		Value: value,
+		Short: true,
+	})
+}
+
+// alarmActions returns the buttons shown on the post that starts an alarm thread.
+func (p *Plugin) alarmActions(channel *TeamChannel, id string) []*model.PostAction {
+	token, err := p.getOrCreateWebhookToken(channel.ChannelID, "")
+	if err != nil {
+		p.API.LogWarn("AWSSNS unable to get the channel token, alarm buttons are not added", "err", err.Error())
+		return nil
+	}
+
+	actionURL := func(path string) string {
+		return fmt.Sprintf("%s/plugins/%s%s?token=%s&channel=%s", *p.API.GetConfig().ServiceSettings.SiteURL, manifest.Id, path, url.QueryEscape(token.Token), url.QueryEscape(channel.NameString()))
+	}
+
+	actions := []*model.PostAction{{
+		Name: "Acknowledge",
+		Type: model.PostActionTypeButton,
+		Integration: &model.PostActionIntegration{
+			URL:     actionURL(alarmAcknowledgePath),
+			Context: map[string]interface{}{"action": "acknowledge", "alarm_id": id},
+		},
+	}}
+	for _, hours := range snoozeDurations {
+		actions = append(actions, &model.PostAction{
+			Name: fmt.Sprintf("Snooze %dh", hours),
+			Type: model.PostActionTypeButton,
+			Integration: &model.PostActionIntegration{
+				URL:     actionURL(alarmSnoozePath),
+				Context: map[string]interface{}{"action": "snooze", "alarm_id": id, "snooze_hours": hours},
+			},
+		})
+	}
+	actions = append(actions, &model.PostAction{
+		Name:  "Mark resolved",
+		Type:  model.PostActionTypeButton,
+		Style: "good",
+		Integration: &model.PostActionIntegration{
+			URL:     actionURL(alarmResolvePath),
+			Context: map[string]interface{}{"action": "resolve", "alarm_id": id},
+		},
+	})
+
+	return actions
+}
+
+// checkAlarmResponder allows the users authorized in the configuration, as well as the
+// responders configured for the channel, to act on alarms.
+func (p *Plugin) checkAlarmResponder(userID, channelID string) error {
+	if err := p.checkAllowedUsers(userID); err == nil {
+		return nil
+	}
+
+	if channel := p.getTeamChannelByID(channelID); channel != nil {
+		for _, responderID := range p.getConfiguration().alarmResponders[channel.NameString()] {
+			if responderID == userID {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("you don't have permissions to respond to this alarm. Please talk with your SysAdmin")
+}
+
+// parseAlarmResponders decodes the AlarmResponders setting, formatted as
+// teamname,channelname=userid1,userid2;teamname-2,channelname-2=userid3
+func parseAlarmResponders(value string) (map[string][]string, error) {
+	responders := map[string][]string{}
+	for _, entry := range strings.Split(value, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		split := strings.SplitN(entry, "=", 2)
+		if len(split) != 2 || len(strings.Split(split[0], ",")) != 2 {
+			return nil, errors.New("alarmResponders setting doesn't follow the pattern $TEAM_NAME,$CHANNEL_NAME=$USER_ID,$USER_ID")
+		}
+
+		channel := strings.TrimSpace(split[0])
+		for _, userID := range strings.Split(split[1], ",") {
+			if userID = strings.TrimSpace(userID); userID != "" {
+				responders[channel] = append(responders[channel], userID)
+			}
+		}
+	}
+	return responders, nil
+}
+
+// handleAlarmAction handles the acknowledge, snooze and resolve buttons of alarm posts.
+func (p *Plugin) handleAlarmAction(w http.ResponseWriter, userID, path string, action *Action) {
+	if action.Context == nil || action.Context.AlarmID == "" {
+		encodeEphermalMessage(w, "SNS BOT Error: The action is missing the alarm.")
+		return
+	}
+
+	post, appErr := p.API.GetPost(action.PostID)
+	if appErr != nil {
+		encodeEphermalMessage(w, "SNS BOT Error: We could not find the alarm post.")
+		p.API.LogError("AWSSNS unable to get the alarm post", "post_id", action.PostID, "err", appErr.Error())
+		return
+	}
+
+	if !p.isAlarmPost(post, action.Context.AlarmID) {
+		encodeEphermalMessage(w, "SNS BOT Error: The action does not belong to the alarm post.")
+		p.API.LogWarn("AWSSNS alarm action does not match its post", "post_id", post.Id, "alarm_id", action.Context.AlarmID)
+		return
+	}
+
+	if err := p.checkAlarmResponder(userID, post.ChannelId); err != nil {
+		encodeEphermalMessage(w, err.Error())
+		return
+	}
+
+	user, appErr := p.API.GetUser(userID)
+	if appErr != nil {
+		encodeEphermalMessage(w, "SNS BOT Error: We could not find your user.")
+		return
+	}
+
+	threadKey := alarmThreadPrefix + action.Context.AlarmID
+	snoozeKey := alarmSnoozePrefix + action.Context.AlarmID
+	now := time.Now()
+	byUser := fmt.Sprintf("@%s on %s", user.Username, now.UTC().Format(alarmActionTimeFormat))
+
+	var reply, response string
+	attachments := post.Attachments()
+	if len(attachments) == 0 {
+		encodeEphermalMessage(w, "SNS BOT Error: The alarm post has no attachment.")
+		return
+	}
+
+	switch path {
+	case alarmAcknowledgePath:
+		thread, err := p.getAlarmThread(threadKey)
+		if err != nil {
+			encodeEphermalMessage(w, err.Error())
+			return
+		}
+		if thread != nil && thread.RootPostID == post.Id {
+			thread.AcknowledgedBy = user.Id
+			thread.AcknowledgedAt = now.UnixMilli()
+			if err = p.storeAlarmThread(threadKey, thread); err != nil {
+				encodeEphermalMessage(w, err.Error())
+				return
+			}
+		}
+		setAttachmentField(attachments[0], acknowledgedFieldTitle, byUser)
+		reply = fmt.Sprintf("Alarm acknowledged by @%s.", user.Username)
+		response = "Alarm acknowledged."
+
+	case alarmSnoozePath:
+		hours := action.Context.SnoozeHours
+		if !isSnoozeDuration(hours) {
+			encodeEphermalMessage(w, "SNS BOT Error: Invalid snooze duration.")
+			return
+		}
+		until := now.Add(time.Duration(hours) * time.Hour)
+		if appErr = p.API.KVSetWithExpiry(snoozeKey, []byte(user.Id), int64(hours)*3600); appErr != nil {
+			encodeEphermalMessage(w, appErr.Error())
+			return
+		}
+		setAttachmentField(attachments[0], snoozedUntilFieldTitle, fmt.Sprintf("%s by @%s", until.UTC().Format(alarmActionTimeFormat), user.Username))
+		reply = fmt.Sprintf("Alarm snoozed for %dh by @%s. No further transitions are posted until %s.", hours, user.Username, until.UTC().Format(alarmActionTimeFormat))
+		response = fmt.Sprintf("Alarm snoozed for %dh.", hours)
+
+	case alarmResolvePath:
+		thread, err := p.getAlarmThread(threadKey)
+		if err != nil {
+			encodeEphermalMessage(w, err.Error())
+			return
+		}
+		// a new transition of the alarm starts a new thread
+		if thread != nil && thread.RootPostID == post.Id {
+			if appErr = p.API.KVDelete(threadKey); appErr != nil {
+				encodeEphermalMessage(w, appErr.Error())
+				return
+			}
+		}
+		if appErr = p.API.KVDelete(snoozeKey); appErr != nil {
+			encodeEphermalMessage(w, appErr.Error())
+			return
+		}
+		setAttachmentField(attachments[0], resolvedFieldTitle, byUser)
+		attachments[0].Actions = nil
+		reply = fmt.Sprintf("Alarm marked resolved by @%s.", user.Username)
+		response = "Alarm marked resolved."
+	}
+
+	model.ParseSlackAttachment(post, attachments)
+	if _, appErr = p.API.UpdatePost(post); appErr != nil {
+		p.API.LogWarn("AWSSNS unable to update the alarm post", "post_id", post.Id, "err", appErr.Error())
+	}
+
+	if _, appErr = p.API.CreatePost(&model.Post{
+		ChannelId: post.ChannelId,
+		UserId:    p.BotUserID,
+		RootId:    post.Id,
+		Message:   reply,
+	}); appErr != nil {
+		p.API.LogWarn("AWSSNS unable to reply in the alarm thread", "post_id", post.Id, "err", appErr.Error())
+	}
+
+	encodeEphermalMessage(w, response)
+}
+
+func isSnoozeDuration(hours int) bool {
+	for _, duration := range snoozeDurations {
+		if duration == hours {
+			return true
+		}
+	}
+	return false
+}
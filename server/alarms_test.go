@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostAlarm(t *testing.T) {
+	channel := &TeamChannel{TeamName: "team1", ChannelName: "channel1", ChannelID: "channelId1"}
+	alarm := SNSMessageNotification{AlarmName: "cpu", AWSAccountID: "123456789012", Region: "US East (N. Virginia)"}
+	key := alarmThreadKey(channel.ChannelID, alarm)
+	snoozeKey := alarmSnoozePrefix + alarmID(channel.ChannelID, alarm)
+	channelToken, err := json.Marshal(&WebhookToken{Token: "channel-token"})
+	require.NoError(t, err)
+
+	t.Run("First transition starts a thread", func(t *testing.T) {
+		alarm.NewStateValue = alarmStateAlarm
+
+		api := &plugintest.API{}
+		api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything).Return()
+		api.On("KVGet", snoozeKey).Return(nil, nil)
+		api.On("KVGet", key).Return(nil, nil)
+		api.On("KVGet", webhookTokenKey(channel.ChannelID, "")).Return(channelToken, nil)
+		api.On("GetConfig").Return(&model.Config{ServiceSettings: model.ServiceSettings{
+			SiteURL: model.NewString("https://mattermost.example.com"),
+		}})
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			attachments := post.Attachments()
+			return post.RootId == "" && len(attachments) == 1 &&
+				attachments[0].Fields[0].Title == currentStateFieldTitle &&
+				attachments[0].Fields[0].Value == alarmStateAlarm &&
+				len(attachments[0].Actions) == 2+len(snoozeDurations) &&
+				post.GetProp(alarmIDProp) == alarmID(channel.ChannelID, alarm)
+		})).Return(&model.Post{Id: "rootPostId"}, nil)
+		api.On("KVSetWithOptions", key, mock.Anything, model.PluginKVSetOptions{
+			Atomic:          true,
+			ExpireInSeconds: int64(threadClaimTTL.Seconds()),
+		}).Return(true, nil)
+		api.On("KVSetWithExpiry", key, mock.MatchedBy(func(b []byte) bool {
+			var thread AlarmThread
+			return json.Unmarshal(b, &thread) == nil && thread.RootPostID == "rootPostId" && thread.State == alarmStateAlarm
+		}), int64(alarmThreadTTL.Seconds())).Return(nil)
+		defer api.AssertExpectations(t)
+
+		p := Plugin{BotUserID: "botUserId"}
+		p.SetAPI(api)
+
+		require.NoError(t, p.postAlarm(p.createSNSMessageNotificationAttachment("ALARM: cpu", alarm), alarm, channel))
+	})
+
+	t.Run("Later transition replies in the thread", func(t *testing.T) {
+		alarm.NewStateValue = alarmStateOK
+
+		thread, err := json.Marshal(&AlarmThread{RootPostID: "rootPostId", State: alarmStateAlarm})
+		require.NoError(t, err)
+
+		root := &model.Post{Id: "rootPostId", ChannelId: channel.ChannelID}
+		model.ParseSlackAttachment(root, []*model.SlackAttachment{{
+			Color: colorRed,
+			Fields: []*model.SlackAttachmentField{
+				{Title: currentStateFieldTitle, Value: alarmStateAlarm},
+			},
+		}})
+
+		api := &plugintest.API{}
+		api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything).Return()
+		api.On("KVGet", snoozeKey).Return(nil, nil)
+		api.On("KVGet", key).Return(thread, nil)
+		api.On("GetPost", "rootPostId").Return(root, nil)
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.RootId == "rootPostId"
+		})).Return(&model.Post{Id: "replyPostId"}, nil)
+		api.On("UpdatePost", mock.MatchedBy(func(post *model.Post) bool {
+			attachments := post.Attachments()
+			return post.Id == "rootPostId" &&
+				attachments[0].Color == colorGreen &&
+				attachments[0].Fields[0].Value == alarmStateOK
+		})).Return(root, nil)
+		api.On("KVDelete", key).Return(nil)
+		defer api.AssertExpectations(t)
+
+		p := Plugin{BotUserID: "botUserId"}
+		p.SetAPI(api)
+
+		require.NoError(t, p.postAlarm(p.createSNSMessageNotificationAttachment("OK: cpu", alarm), alarm, channel))
+	})
+
+	t.Run("Transition without a thread is posted on its own", func(t *testing.T) {
+		alarm.NewStateValue = alarmStateOK
+
+		api := &plugintest.API{}
+		api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything).Return()
+		api.On("KVGet", snoozeKey).Return(nil, nil)
+		api.On("KVGet", key).Return(nil, nil)
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			attachments := post.Attachments()
+			return post.RootId == "" && len(attachments) == 1 && len(attachments[0].Actions) == 0
+		})).Return(&model.Post{Id: "postId"}, nil)
+		defer api.AssertExpectations(t)
+
+		p := Plugin{BotUserID: "botUserId"}
+		p.SetAPI(api)
+
+		require.NoError(t, p.postAlarm(p.createSNSMessageNotificationAttachment("OK: cpu", alarm), alarm, channel))
+		api.AssertNotCalled(t, "KVSetWithOptions", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Thread started by another node is replied to", func(t *testing.T) {
+		alarm.NewStateValue = alarmStateAlarm
+
+		thread, err := json.Marshal(&AlarmThread{RootPostID: "rootPostId", State: alarmStateAlarm})
+		require.NoError(t, err)
+
+		root := &model.Post{Id: "rootPostId", ChannelId: channel.ChannelID}
+		model.ParseSlackAttachment(root, []*model.SlackAttachment{{
+			Fields: []*model.SlackAttachmentField{
+				{Title: currentStateFieldTitle, Value: alarmStateAlarm},
+			},
+		}})
+
+		api := &plugintest.API{}
+		api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything).Return()
+		api.On("KVGet", snoozeKey).Return(nil, nil)
+		api.On("KVGet", key).Return(nil, nil).Once()
+		api.On("KVGet", key).Return(thread, nil).Once()
+		api.On("KVGet", webhookTokenKey(channel.ChannelID, "")).Return(channelToken, nil)
+		api.On("GetConfig").Return(&model.Config{ServiceSettings: model.ServiceSettings{
+			SiteURL: model.NewString("https://mattermost.example.com"),
+		}})
+		api.On("KVSetWithOptions", key, mock.Anything, mock.Anything).Return(false, nil)
+		api.On("GetPost", "rootPostId").Return(root, nil)
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.RootId == "rootPostId"
+		})).Return(&model.Post{Id: "replyPostId"}, nil)
+		api.On("UpdatePost", mock.Anything).Return(root, nil)
+		api.On("KVSetWithExpiry", key, mock.Anything, int64(alarmThreadTTL.Seconds())).Return(nil)
+		defer api.AssertExpectations(t)
+
+		p := Plugin{BotUserID: "botUserId"}
+		p.SetAPI(api)
+
+		require.NoError(t, p.postAlarm(p.createSNSMessageNotificationAttachment("ALARM: cpu", alarm), alarm, channel))
+	})
+
+	t.Run("Snoozed alarm is not posted", func(t *testing.T) {
+		alarm.NewStateValue = alarmStateAlarm
+
+		api := &plugintest.API{}
+		api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+		api.On("KVGet", snoozeKey).Return([]byte("userId"), nil)
+		defer api.AssertExpectations(t)
+
+		p := Plugin{BotUserID: "botUserId"}
+		p.SetAPI(api)
+
+		require.NoError(t, p.postAlarm(p.createSNSMessageNotificationAttachment("ALARM: cpu", alarm), alarm, channel))
+		api.AssertNotCalled(t, "CreatePost", mock.Anything)
+	})
+}
+
+func TestHandleAlarmAction(t *testing.T) {
+	channel := &TeamChannel{TeamName: "team1", ChannelName: "channel1", ChannelID: "channelId1"}
+	root := &model.Post{Id: "rootPostId", ChannelId: channel.ChannelID}
+	model.ParseSlackAttachment(root, []*model.SlackAttachment{{Color: colorRed}})
+	root.AddProp(alarmIDProp, "alarmId1")
+
+	request := func(userID string, action *Action) *http.Request {
+		b, err := json.Marshal(action)
+		require.NoError(t, err)
+		r := httptest.NewRequest("POST", alarmAcknowledgePath, bytes.NewReader(b))
+		r.Header.Set("Mattermost-User-Id", userID)
+		return r
+	}
+
+	for name, test := range map[string]struct {
+		UserID   string
+		Action   *Action
+		Expected string
+	}{
+		"User of the request acknowledges": {
+			UserID:   "adminId",
+			Action:   &Action{PostID: root.Id, Context: &ActionContext{AlarmID: "alarmId1"}},
+			Expected: "Alarm acknowledged.",
+		},
+		"User of the body is ignored": {
+			UserID:   "someoneElse",
+			Action:   &Action{UserID: "adminId", PostID: root.Id, Context: &ActionContext{AlarmID: "alarmId1"}},
+			Expected: "you don't have permissions to respond to this alarm",
+		},
+		"Alarm of another post is rejected": {
+			UserID:   "adminId",
+			Action:   &Action{PostID: root.Id, Context: &ActionContext{AlarmID: "alarmId2"}},
+			Expected: "SNS BOT Error: The action does not belong to the alarm post.",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			api := &plugintest.API{}
+			api.On("GetPost", root.Id).Return(root.Clone(), nil)
+			api.On("GetUser", "adminId").Return(&model.User{Id: "adminId", Username: "admin"}, nil)
+			api.On("KVGet", alarmThreadPrefix+"alarmId1").Return(nil, nil)
+			api.On("UpdatePost", mock.Anything).Return(root, nil)
+			api.On("CreatePost", mock.Anything).Return(&model.Post{}, nil)
+			api.On("LogWarn", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+
+			p := Plugin{BotUserID: "botUserId"}
+			p.SetAPI(api)
+			p.setConfiguration(&configuration{AllowedUserIds: "adminId"})
+
+			w := httptest.NewRecorder()
+			p.handleAction(w, request(test.UserID, test.Action))
+
+			var response map[string]interface{}
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+			assert.Contains(t, response["ephemeral_text"], test.Expected)
+		})
+	}
+}
+
+func TestCheckAlarmResponder(t *testing.T) {
+	responders, err := parseAlarmResponders("team1,channel1=responderId1, responderId2;team1,channel2=responderId3")
+	require.NoError(t, err)
+
+	_, err = parseAlarmResponders("team1=responderId1")
+	assert.Error(t, err)
+
+	p := Plugin{Channels: []*TeamChannel{
+		{TeamName: "team1", ChannelName: "channel1", ChannelID: "channelId1"},
+		{TeamName: "team1", ChannelName: "channel2", ChannelID: "channelId2"},
+	}}
+	p.setConfiguration(&configuration{AllowedUserIds: "adminId", alarmResponders: responders})
+
+	assert.NoError(t, p.checkAlarmResponder("adminId", "channelId1"))
+	assert.NoError(t, p.checkAlarmResponder("responderId2", "channelId1"))
+	assert.Error(t, p.checkAlarmResponder("responderId3", "channelId1"))
+	assert.NoError(t, p.checkAlarmResponder("responderId3", "channelId2"))
+	assert.Error(t, p.checkAlarmResponder("someoneElse", "channelId2"))
+}
+
+func TestAlarmStateColor(t *testing.T) {
+	assert.Equal(t, colorRed, alarmStateColor(alarmStateAlarm))
+	assert.Equal(t, colorYellow, alarmStateColor(alarmStateInsufficientData))
+	assert.Equal(t, colorGreen, alarmStateColor(alarmStateOK))
+}
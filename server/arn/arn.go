@@ -0,0 +1,91 @@
+// Package arn parses and validates Amazon Resource Names.
+package arn
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Partitions are the AWS partitions an ARN can belong to.
+var Partitions = []string{"aws", "aws-cn", "aws-us-gov"}
+
+// ARN holds the parts of arn:partition:service:region:account-id:resource. Region and AccountID
+// are empty for global resources such as S3 buckets.
+type ARN struct {
+	Partition string
+	Service   string
+	Region    string
+	AccountID string
+	Resource  string
+}
+
+// Parse splits an ARN into its parts. The resource keeps any colons or slashes it contains.
+func Parse(value string) (ARN, error) {
+	parts := strings.SplitN(value, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" {
+		return ARN{}, errors.Errorf("%q is not an ARN", value)
+	}
+
+	a := ARN{
+		Partition: parts[1],
+		Service:   parts[2],
+		Region:    parts[3],
+		AccountID: parts[4],
+		Resource:  parts[5],
+	}
+	if !isPartition(a.Partition) {
+		return ARN{}, errors.Errorf("ARN %q has an unknown partition %q", value, a.Partition)
+	}
+	if a.Service == "" {
+		return ARN{}, errors.Errorf("ARN %q has no service", value)
+	}
+	if a.Resource == "" {
+		return ARN{}, errors.Errorf("ARN %q has no resource", value)
+	}
+	if a.AccountID != "" && !isAccountID(a.AccountID) {
+		return ARN{}, errors.Errorf("ARN %q has an invalid account id %q", value, a.AccountID)
+	}
+	return a, nil
+}
+
+// IsARN reports whether value is a valid ARN.
+func IsARN(value string) bool {
+	_, err := Parse(value)
+	return err == nil
+}
+
+// String joins the parts back into an ARN.
+func (a ARN) String() string {
+	return strings.Join([]string{"arn", a.Partition, a.Service, a.Region, a.AccountID, a.Resource}, ":")
+}
+
+// ResourceName returns the resource without its type, e.g. default for the resource
+// cluster/default and my-topic for the resource of an SNS topic.
+func (a ARN) ResourceName() string {
+	if i := strings.Index(a.Resource, "/"); i >= 0 {
+		return a.Resource[i+1:]
+	}
+	return a.Resource
+}
+
+func isPartition(partition string) bool {
+	for _, p := range Partitions {
+		if p == partition {
+			return true
+		}
+	}
+	return false
+}
+
+func isAccountID(accountID string) bool {
+	if len(accountID) != 12 {
+		return false
+	}
+	for _, r := range accountID {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+package arn
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	for name, test := range map[string]struct {
+		Value         string
+		Expected      ARN
+		ExpectedError bool
+	}{
+		"SNS topic": {
+			Value:    "arn:aws:sns:us-east-1:123456789012:alerts",
+			Expected: ARN{Partition: "aws", Service: "sns", Region: "us-east-1", AccountID: "123456789012", Resource: "alerts"},
+		},
+		"China partition": {
+			Value:    "arn:aws-cn:sns:cn-north-1:123456789012:alerts",
+			Expected: ARN{Partition: "aws-cn", Service: "sns", Region: "cn-north-1", AccountID: "123456789012", Resource: "alerts"},
+		},
+		"GovCloud partition": {
+			Value:    "arn:aws-us-gov:sns:us-gov-west-1:123456789012:alerts",
+			Expected: ARN{Partition: "aws-us-gov", Service: "sns", Region: "us-gov-west-1", AccountID: "123456789012", Resource: "alerts"},
+		},
+		"Resource with colons": {
+			Value:    "arn:aws:ecs:us-east-1:123456789012:task-definition/web:3",
+			Expected: ARN{Partition: "aws", Service: "ecs", Region: "us-east-1", AccountID: "123456789012", Resource: "task-definition/web:3"},
+		},
+		"Global resource": {
+			Value:    "arn:aws:s3:::my-bucket",
+			Expected: ARN{Partition: "aws", Service: "s3", Resource: "my-bucket"},
+		},
+		"Too few parts": {
+			Value:         "arn:aws:sns:us-east-1:alerts",
+			ExpectedError: true,
+		},
+		"Not an ARN": {
+			Value:         "alerts",
+			ExpectedError: true,
+		},
+		"Unknown partition": {
+			Value:         "arn:aws-moon:sns:us-east-1:123456789012:alerts",
+			ExpectedError: true,
+		},
+		"Missing service": {
+			Value:         "arn:aws::us-east-1:123456789012:alerts",
+			ExpectedError: true,
+		},
+		"Invalid account id": {
+			Value:         "arn:aws:sns:us-east-1:12345:alerts",
+			ExpectedError: true,
+		},
+		"Missing resource": {
+			Value:         "arn:aws:sns:us-east-1:123456789012:",
+			ExpectedError: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			parsed, err := Parse(test.Value)
+			if test.ExpectedError {
+				assert.Error(t, err)
+				assert.False(t, IsARN(test.Value))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.Expected, parsed)
+			assert.Equal(t, test.Value, parsed.String())
+		})
+	}
+}
+
+func TestResourceName(t *testing.T) {
+	assert.Equal(t, "default", ARN{Resource: "cluster/default"}.ResourceName())
+	assert.Equal(t, "web:3", ARN{Resource: "task-definition/web:3"}.ResourceName())
+	assert.Equal(t, "alerts", ARN{Resource: "alerts"}.ResourceName())
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	autoScalingBurstPrefix = "asgBurst_"
+	// maxBurstInstances caps the number of instances listed on a burst summary.
+	maxBurstInstances = 20
+
+	autoScalingService        = "AWS Auto Scaling"
+	autoScalingLaunch         = "autoscaling:EC2_INSTANCE_LAUNCH"
+	autoScalingLaunchError    = "autoscaling:EC2_INSTANCE_LAUNCH_ERROR"
+	autoScalingTerminate      = "autoscaling:EC2_INSTANCE_TERMINATE"
+	autoScalingTerminateError = "autoscaling:EC2_INSTANCE_TERMINATE_ERROR"
+)
+
+// errAutoScalingBurstOver stops linking a summary post to a burst that was replaced meanwhile.
+var errAutoScalingBurstOver = errors.New("the Auto Scaling burst is over")
+
+// AutoScalingBurst counts the launches and terminations of an Auto Scaling group collapsed into
+// one summary post.
+type AutoScalingBurst struct {
+	PostID     string
+	ExpiresAt  int64
+	Launched   int
+	Terminated int
+	Failed     int
+	Instances  []string
+}
+
+// autoScalingParser handles the notifications Auto Scaling groups send directly to SNS.
+type autoScalingParser struct {
+	p *Plugin
+}
+
+func (a *autoScalingParser) Name() string  { return "autoscaling" }
+func (a *autoScalingParser) Priority() int { return 400 }
+
+func (a *autoScalingParser) Detect(notification *SNSNotification) bool {
+	isAutoScalingEvent, _ := a.p.isAutoScalingEvent(notification.Message)
+	return isAutoScalingEvent
+}
+
+func (a *autoScalingParser) Parse(notification *SNSNotification) (interface{}, error) {
+	isAutoScalingEvent, messageNotification := a.p.isAutoScalingEvent(notification.Message)
+	if !isAutoScalingEvent {
+		return nil, errors.New("message is not an Auto Scaling notification")
+	}
+	return messageNotification, nil
+}
+
+func (a *autoScalingParser) Render(notification *SNSNotification, event interface{}) []*model.SlackAttachment {
+	attachment := a.p.createSNSAutoScalingAttachment(event.(SNSAutoScalingNotification))
+	return []*model.SlackAttachment{&attachment}
+}
+
+// Post collapses launches and terminations into a summary post per group when a burst window is
+// configured. Other events are posted as they are.
+func (a *autoScalingParser) Post(event interface{}, attachments []*model.SlackAttachment, channels []*TeamChannel) {
+	messageNotification := event.(SNSAutoScalingNotification)
+	window := time.Duration(a.p.getConfiguration().AutoScalingBurstMinutes) * time.Minute
+	if window <= 0 || !isAutoScalingScaleEvent(messageNotification.Event) {
+		a.p.sendPostNotification(attachments, channels)
+		return
+	}
+
+	for _, channel := range channels {
+		if err := a.p.postAutoScalingBurst(messageNotification, attachments, channel, window); err != nil {
+			a.p.API.LogError("AWSSNS unable to post the Auto Scaling event", "channel_id", channel.ChannelID, "group", messageNotification.AutoScalingGroupName, "err", err.Error())
+		}
+	}
+}
+
+func (p *Plugin) isAutoScalingEvent(message string) (bool, SNSAutoScalingNotification) {
+	var messageNotification SNSAutoScalingNotification
+	if err := json.Unmarshal([]byte(message), &messageNotification); err != nil {
+		p.API.LogDebug(
+			"AWSSNS HandleNotification Decode Error on Auto Scaling message notification",
+			"err", err.Error(),
+			"message", message)
+		return false, messageNotification
+	}
+	return messageNotification.Service == autoScalingService &&
+		messageNotification.AutoScalingGroupName != "" &&
+		(messageNotification.Event != "" || messageNotification.LifecycleTransition != ""), messageNotification
+}
+
+func (p *Plugin) createSNSAutoScalingAttachment(messageNotification SNSAutoScalingNotification) model.SlackAttachment {
+	p.API.LogDebug("AWSSNS HandleNotification Auto Scaling Event", "GROUP", messageNotification.AutoScalingGroupName)
+
+	event := messageNotification.Event
+	if event == "" {
+		event = messageNotification.LifecycleTransition
+	}
+
+	var fields []*model.SlackAttachmentField
+
+	fields = addFields(fields, "Auto Scaling Group", messageNotification.AutoScalingGroupName, true)
+	fields = addFields(fields, "Event", event, true)
+	if messageNotification.StatusCode != "" {
+		fields = addFields(fields, "Status", messageNotification.StatusCode, true)
+	}
+	if messageNotification.EC2InstanceID != "" {
+		fields = addFields(fields, "EC2 Instance", messageNotification.EC2InstanceID, true)
+	}
+	if zone := messageNotification.Details["Availability Zone"]; zone != "" {
+		fields = addFields(fields, "Availability Zone", zone, true)
+	}
+	if messageNotification.LifecycleHookName != "" {
+		fields = addFields(fields, "Lifecycle Hook", messageNotification.LifecycleHookName, true)
+	}
+	fields = addFields(fields, "AWS Account", messageNotification.AccountID, true)
+	if messageNotification.Cause != "" {
+		fields = addFields(fields, "Cause", messageNotification.Cause, false)
+	}
+	if messageNotification.StatusMessage != "" {
+		fields = addFields(fields, "Status Message", messageNotification.StatusMessage, false)
+	}
+
+	return model.SlackAttachment{
+		Title:  fmt.Sprintf("Auto Scaling %s", strings.TrimPrefix(event, "autoscaling:")),
+		Text:   messageNotification.Description,
+		Fields: fields,
+		Color:  autoScalingEventColor(messageNotification.Event),
+		Footer: messageNotification.AutoScalingGroupARN,
+	}
+}
+
+// postAutoScalingBurst posts the first scale event of a group in a window as a summary, and
+// updates that summary with the events that follow until the window is over. The burst is
+// counted atomically, so concurrent events are all counted and only one summary is posted.
+func (p *Plugin) postAutoScalingBurst(messageNotification SNSAutoScalingNotification, attachments []*model.SlackAttachment, channel *TeamChannel, window time.Duration) error {
+	sum := sha256.Sum256([]byte(messageNotification.AccountID + "/" + messageNotification.AutoScalingGroupName + "/" + channel.ChannelID))
+	key := autoScalingBurstPrefix + hex.EncodeToString(sum[:16])
+
+	var burst AutoScalingBurst
+	var started bool
+	err := p.client.KV.SetAtomicWithRetries(key, func(oldValue []byte) (interface{}, error) {
+		now := model.GetMillis()
+		burst, started = AutoScalingBurst{}, false
+		if oldValue != nil {
+			if err := json.Unmarshal(oldValue, &burst); err != nil {
+				return nil, errors.Wrap(err, "failed to unmarshal Auto Scaling burst")
+			}
+		}
+
+		if burst.ExpiresAt > now && burst.PostID != "" && p.getAutoScalingBurstPost(burst.PostID) == nil {
+			p.API.LogDebug("AWSSNS Auto Scaling summary post is gone, starting a new one", "post_id", burst.PostID)
+			burst.ExpiresAt = 0
+		}
+		if burst.ExpiresAt <= now {
+			burst = AutoScalingBurst{ExpiresAt: now + window.Milliseconds()}
+			started = true
+		}
+		burst.add(messageNotification)
+		return &burst, nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to count the Auto Scaling event")
+	}
+
+	if started {
+		return p.startAutoScalingBurst(messageNotification, attachments, channel, key, &burst)
+	}
+	if burst.PostID == "" {
+		// the summary of the burst is being posted and shows this event once it is
+		return nil
+	}
+
+	post := p.getAutoScalingBurstPost(burst.PostID)
+	if post == nil {
+		return errors.Errorf("the Auto Scaling summary post %s is gone", burst.PostID)
+	}
+	model.ParseSlackAttachment(post, append([]*model.SlackAttachment{createAutoScalingBurstAttachment(messageNotification, &burst)}, attachments...))
+	if _, appErr := p.API.UpdatePost(post); appErr != nil {
+		return appErr
+	}
+	return nil
+}
+
+// startAutoScalingBurst posts the summary of a new burst and links it to the burst. Events
+// counted while the summary was posted are added to it.
+func (p *Plugin) startAutoScalingBurst(messageNotification SNSAutoScalingNotification, attachments []*model.SlackAttachment, channel *TeamChannel, key string, burst *AutoScalingBurst) error {
+	post, err := p.createBotPost(channel, "", append([]*model.SlackAttachment{createAutoScalingBurstAttachment(messageNotification, burst)}, attachments...))
+	if err != nil {
+		return err
+	}
+
+	posted := *burst
+	err = p.client.KV.SetAtomicWithRetries(key, func(oldValue []byte) (interface{}, error) {
+		if oldValue == nil {
+			return nil, errAutoScalingBurstOver
+		}
+		if err := json.Unmarshal(oldValue, burst); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal Auto Scaling burst")
+		}
+		if burst.ExpiresAt != posted.ExpiresAt || burst.PostID != "" {
+			return nil, errAutoScalingBurstOver
+		}
+		burst.PostID = post.Id
+		return burst, nil
+	})
+	if errors.Is(err, errAutoScalingBurstOver) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to store the Auto Scaling summary post")
+	}
+
+	if burst.Launched+burst.Terminated+burst.Failed == posted.Launched+posted.Terminated+posted.Failed {
+		return nil
+	}
+	model.ParseSlackAttachment(post, append([]*model.SlackAttachment{createAutoScalingBurstAttachment(messageNotification, burst)}, attachments...))
+	if _, appErr := p.API.UpdatePost(post); appErr != nil {
+		return appErr
+	}
+	return nil
+}
+
+// getAutoScalingBurstPost returns the summary post of a burst, or nil when it was deleted.
+func (p *Plugin) getAutoScalingBurstPost(postID string) *model.Post {
+	post, appErr := p.API.GetPost(postID)
+	if appErr != nil || post.DeleteAt != 0 {
+		return nil
+	}
+	return post
+}
+
+func (b *AutoScalingBurst) add(messageNotification SNSAutoScalingNotification) {
+	switch messageNotification.Event {
+	case autoScalingLaunch:
+		b.Launched++
+	case autoScalingTerminate:
+		b.Terminated++
+	default:
+		b.Failed++
+	}
+	if messageNotification.EC2InstanceID != "" {
+		b.Instances = append(b.Instances, messageNotification.EC2InstanceID)
+	}
+}
+
+// createAutoScalingBurstAttachment summarizes the burst, the latest event is rendered below it.
+func createAutoScalingBurstAttachment(messageNotification SNSAutoScalingNotification, burst *AutoScalingBurst) *model.SlackAttachment {
+	var fields []*model.SlackAttachmentField
+
+	fields = addFields(fields, "Launched", strconv.Itoa(burst.Launched), true)
+	fields = addFields(fields, "Terminated", strconv.Itoa(burst.Terminated), true)
+	fields = addFields(fields, "Failed", strconv.Itoa(burst.Failed), true)
+
+	instances := burst.Instances
+	if len(instances) > maxBurstInstances {
+		instances = append(instances[:maxBurstInstances:maxBurstInstances], fmt.Sprintf("and %d more", len(burst.Instances)-maxBurstInstances))
+	}
+	fields = addFields(fields, "Instances", strings.Join(instances, "\n"), false)
+
+	color := colorBlue
+	if burst.Failed > 0 {
+		color = colorRed
+	}
+
+	return &model.SlackAttachment{
+		Title:  fmt.Sprintf("Auto Scaling group %s: %d scale events", messageNotification.AutoScalingGroupName, burst.Launched+burst.Terminated+burst.Failed),
+		Text:   fmt.Sprintf("Events until %s are collapsed into this post. The latest one is shown below.", time.UnixMilli(burst.ExpiresAt).UTC().Format(time.RFC1123)),
+		Fields: fields,
+		Color:  color,
+	}
+}
+
+func isAutoScalingScaleEvent(event string) bool {
+	switch event {
+	case autoScalingLaunch, autoScalingLaunchError, autoScalingTerminate, autoScalingTerminateError:
+		return true
+	default:
+		return false
+	}
+}
+
+func autoScalingEventColor(event string) string {
+	switch event {
+	case autoScalingLaunch:
+		return colorGreen
+	case autoScalingTerminate:
+		return colorYellow
+	case autoScalingLaunchError, autoScalingTerminateError:
+		return colorRed
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func autoScalingMessage(event, instanceID string) string {
+	return `{
+		"Origin": "EC2",
+		"Destination": "AutoScalingGroup",
+		"Progress": 50,
+		"AccountId": "123456789012",
+		"Description": "Launching a new EC2 instance: ` + instanceID + `",
+		"RequestId": "1a2b3c4d",
+		"EndTime": "2023-04-01T10:00:32.471Z",
+		"AutoScalingGroupARN": "arn:aws:autoscaling:us-east-1:123456789012:autoScalingGroup:0a1b:autoScalingGroupName/web",
+		"ActivityId": "1a2b3c4d",
+		"StartTime": "2023-04-01T10:00:00.123Z",
+		"Service": "AWS Auto Scaling",
+		"Time": "2023-04-01T10:00:32.471Z",
+		"EC2InstanceId": "` + instanceID + `",
+		"StatusCode": "InProgress",
+		"StatusMessage": "",
+		"Details": {"Subnet ID": "subnet-1", "Availability Zone": "us-east-1a"},
+		"AutoScalingGroupName": "web",
+		"Cause": "An instance was started in response to a difference between desired and actual capacity.",
+		"Event": "` + event + `"
+	}`
+}
+
+func TestAutoScalingParserRender(t *testing.T) {
+	api := &plugintest.API{}
+	api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything).Maybe()
+	p := &Plugin{}
+	p.SetAPI(api)
+	parser := &autoScalingParser{p: p}
+
+	notification := &SNSNotification{Message: autoScalingMessage(autoScalingLaunch, "i-1")}
+	require.True(t, parser.Detect(notification))
+	assert.True(t, parser.Detect(&SNSNotification{Message: `{"Service": "AWS Auto Scaling", "AutoScalingGroupName": "web", "LifecycleTransition": "autoscaling:EC2_INSTANCE_LAUNCHING", "LifecycleHookName": "hook"}`}))
+	assert.False(t, parser.Detect(&SNSNotification{Message: `{"AlarmName": "cpu"}`}))
+
+	event, err := parser.Parse(notification)
+	require.NoError(t, err)
+	attachments := parser.Render(notification, event)
+	require.Len(t, attachments, 1)
+
+	assert.Equal(t, "Auto Scaling EC2_INSTANCE_LAUNCH", attachments[0].Title)
+	assert.Equal(t, colorGreen, attachments[0].Color)
+	values := renderFields(attachments[0])
+	assert.Equal(t, "web", values["Auto Scaling Group"])
+	assert.Equal(t, "i-1", values["EC2 Instance"])
+	assert.Equal(t, "us-east-1a", values["Availability Zone"])
+	assert.NotContains(t, values, "Status Message")
+}
+
+func TestAutoScalingParserPost(t *testing.T) {
+	channel := &TeamChannel{ChannelID: "channelId1"}
+
+	t.Run("Burst window disabled", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything).Maybe()
+		p := &Plugin{BotUserID: "botId"}
+		p.SetAPI(api)
+		p.setConfiguration(&configuration{})
+		parser := &autoScalingParser{p: p}
+
+		api.On("CreatePost", mock.AnythingOfType("*model.Post")).Return(&model.Post{Id: "postId"}, nil).Once()
+
+		notification := &SNSNotification{Message: autoScalingMessage(autoScalingLaunch, "i-1")}
+		event, err := parser.Parse(notification)
+		require.NoError(t, err)
+		parser.Post(event, parser.Render(notification, event), []*TeamChannel{channel})
+
+		api.AssertExpectations(t)
+	})
+
+	t.Run("Burst collapsed into one post", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything).Maybe()
+		p := &Plugin{BotUserID: "botId"}
+		p.SetAPI(api)
+		p.client = pluginapi.NewClient(api, &plugintest.Driver{})
+		p.setConfiguration(&configuration{AutoScalingBurstMinutes: 10})
+		parser := &autoScalingParser{p: p}
+
+		var stored []byte
+		var post *model.Post
+		api.On("KVGet", mock.AnythingOfType("string")).Return(func(key string) ([]byte, *model.AppError) {
+			return stored, nil
+		})
+		api.On("KVSetWithOptions", mock.AnythingOfType("string"), mock.Anything, mock.Anything).Return(func(key string, value []byte, options model.PluginKVSetOptions) (bool, *model.AppError) {
+			if !options.Atomic || !bytes.Equal(options.OldValue, stored) {
+				return false, nil
+			}
+			stored = value
+			return true, nil
+		})
+		api.On("CreatePost", mock.AnythingOfType("*model.Post")).Return(func(created *model.Post) (*model.Post, *model.AppError) {
+			created.Id = "postId"
+			post = created
+			return created, nil
+		}).Once()
+		api.On("GetPost", "postId").Return(func(string) (*model.Post, *model.AppError) {
+			return post, nil
+		})
+		api.On("UpdatePost", mock.AnythingOfType("*model.Post")).Return(func(updated *model.Post) (*model.Post, *model.AppError) {
+			post = updated
+			return updated, nil
+		}).Twice()
+
+		for _, message := range []string{
+			autoScalingMessage(autoScalingLaunch, "i-1"),
+			autoScalingMessage(autoScalingLaunch, "i-2"),
+			autoScalingMessage(autoScalingLaunchError, ""),
+		} {
+			notification := &SNSNotification{Message: message}
+			event, err := parser.Parse(notification)
+			require.NoError(t, err)
+			parser.Post(event, parser.Render(notification, event), []*TeamChannel{channel})
+		}
+		api.AssertExpectations(t)
+
+		attachments := post.Attachments()
+		require.Len(t, attachments, 2)
+		assert.Equal(t, "Auto Scaling group web: 3 scale events", attachments[0].Title)
+		assert.Equal(t, colorRed, attachments[0].Color)
+		values := renderFields(attachments[0])
+		assert.Equal(t, "2", values["Launched"])
+		assert.Equal(t, "1", values["Failed"])
+		assert.Equal(t, "i-1\ni-2", values["Instances"])
+		assert.Equal(t, "Auto Scaling EC2_INSTANCE_LAUNCH_ERROR", attachments[1].Title)
+
+		var burst AutoScalingBurst
+		require.NoError(t, json.Unmarshal(stored, &burst))
+		assert.Equal(t, "postId", burst.PostID)
+	})
+}
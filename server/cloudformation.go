@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+// cloudformationEventParser handles CloudFormation stack events, which are sent as key='value'
+// lines rather than JSON.
+type cloudformationEventParser struct {
+	p *Plugin
+}
+
+func (c *cloudformationEventParser) Name() string  { return "cloudformation" }
+func (c *cloudformationEventParser) Priority() int { return 100 }
+
+func (c *cloudformationEventParser) Detect(notification *SNSNotification) bool {
+	isCloudformationEvent, _ := c.p.isCloudformationEvent(notification.Message)
+	return isCloudformationEvent
+}
+
+func (c *cloudformationEventParser) Parse(notification *SNSNotification) (interface{}, error) {
+	isCloudformationEvent, messageNotification := c.p.isCloudformationEvent(notification.Message)
+	if !isCloudformationEvent {
+		return nil, errors.New("message is not a CloudFormation event")
+	}
+	return messageNotification, nil
+}
+
+func (c *cloudformationEventParser) Render(notification *SNSNotification, event interface{}) []*model.SlackAttachment {
+	attachment := c.p.createSNSCloudformationEventAttachment(notification.Subject, event.(SNSCloudformationEventNotification))
+	return []*model.SlackAttachment{&attachment}
+}
+
+func (p *Plugin) isCloudformationEvent(message string) (bool, SNSCloudformationEventNotification) {
+	var messageNotification SNSCloudformationEventNotification
+
+	// alter message in order to decode it in json format
+	messagejson, err := messageToJSON(message)
+
+	if err != nil {
+		p.API.LogDebug(
+			"AWSSNS HandleNotification Decode Error on Cloudformation-Event message notification",
+			"err", err.Error(),
+			"message", message)
+		return false, messageNotification
+	}
+
+	if messagejson != nil {
+		if err := json.Unmarshal(messagejson, &messageNotification); err != nil {
+			p.API.LogError(
+				"AWSSNS HandleNotification Decode Error on Cloudformation-Event message notification",
+				"err", err.Error(),
+				"message", message)
+			return false, messageNotification
+		}
+		return len(messageNotification.EventID) > 0, messageNotification
+	}
+	return false, messageNotification
+}
+
+func (p *Plugin) createSNSCloudformationEventAttachment(subject string, messageNotification SNSCloudformationEventNotification) model.SlackAttachment {
+	p.API.LogDebug("AWSSNS HandleNotification Cloudformation Event", "SUBJECT", subject)
+	var fields []*model.SlackAttachmentField
+
+	fields = addFields(fields, "StackId", messageNotification.StackID, true)
+	fields = addFields(fields, "StackName", messageNotification.StackName, true)
+	fields = addFields(fields, "LogicalResourceId", messageNotification.LogicalResourceID, true)
+	fields = addFields(fields, "PhysicalResourceId", messageNotification.PhysicalResourceID, true)
+	fields = addFields(fields, "ResourceType", messageNotification.ResourceType, true)
+	fields = addFields(fields, "Timestamp", messageNotification.Timestamp, true)
+	fields = addFields(fields, "ResourceStatus", messageNotification.ResourceStatus, true)
+
+	attachment := model.SlackAttachment{
+		Title:     subject,
+		TitleLink: stackEventsConsoleURL(messageNotification.StackID),
+		Fields:    fields,
+	}
+
+	return attachment
+}
+
+func messageToJSON(message string) ([]byte, error) {
+	messagefields := strings.Split(message, "\n")
+	if len(messagefields) == 0 {
+		return nil, errors.New("no message fields present in message string")
+	}
+	// examine if the message refers to a cloudformation event by checking if a valid StackId field is included in the first line
+	stackIDParts := strings.Split(messagefields[0], "=")
+	if len(stackIDParts) == 2 && stackIDParts[0] == "StackId" {
+		containsCloudformationArn := strings.Contains(stackIDParts[1], "arn:aws:cloudformation")
+		if !containsCloudformationArn {
+			return nil, errors.New("invalid value of StackId field")
+		}
+	} else {
+		return nil, nil
+	}
+
+	var numOfFields int
+
+	// if "\n" existed at the end of the message, do not parse the last field
+	if messagefields[len(messagefields)-1] == "" {
+		numOfFields = len(messagefields) - 1
+	} else {
+		numOfFields = len(messagefields)
+	}
+
+	//split each line of the cloudformation event message to field and value
+	var fields = make(map[string]string)
+	for _, field := range messagefields[:numOfFields] {
+		parts := strings.Split(field, "=")
+		if len(parts) == 2 && parts[1] != "" {
+			fields[parts[0]] = parts[1]
+		} else {
+			return nil, errors.New("format of Cloudformation event message is incorrect")
+		}
+	}
+
+	jsonmessage, err := json.Marshal(fields)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error marshaling in messageToJSON")
+	}
+	return jsonmessage, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+// cloudWatchAlarmParser handles CloudWatch alarm state changes. Alarms are threaded per alarm
+// and can be matched by notification rules.
+type cloudWatchAlarmParser struct {
+	p *Plugin
+}
+
+func (c *cloudWatchAlarmParser) Name() string  { return "cloudwatch" }
+func (c *cloudWatchAlarmParser) Priority() int { return 300 }
+
+func (c *cloudWatchAlarmParser) Detect(notification *SNSNotification) bool {
+	isAlarm, _ := c.p.isCloudWatchAlarm(notification.Message)
+	return isAlarm
+}
+
+func (c *cloudWatchAlarmParser) Parse(notification *SNSNotification) (interface{}, error) {
+	isAlarm, messageNotification := c.p.isCloudWatchAlarm(notification.Message)
+	if !isAlarm {
+		return nil, errors.New("message is not a CloudWatch alarm")
+	}
+	return messageNotification, nil
+}
+
+func (c *cloudWatchAlarmParser) Render(notification *SNSNotification, event interface{}) []*model.SlackAttachment {
+	attachment := c.p.createSNSMessageNotificationAttachment(notification.Subject, event.(SNSMessageNotification))
+	return []*model.SlackAttachment{&attachment}
+}
+
+func (c *cloudWatchAlarmParser) RuleSubject(event interface{}) *ruleSubject {
+	return newAlarmRuleSubject(event.(SNSMessageNotification))
+}
+
+func (c *cloudWatchAlarmParser) Post(event interface{}, attachments []*model.SlackAttachment, channels []*TeamChannel) {
+	c.p.sendAlarmNotification(*attachments[0], event.(SNSMessageNotification), channels)
+}
+
+func (p *Plugin) isCloudWatchAlarm(message string) (bool, SNSMessageNotification) {
+	var messageNotification SNSMessageNotification
+	if err := json.Unmarshal([]byte(message), &messageNotification); err != nil {
+		p.API.LogDebug(
+			"AWSSNS HandleNotification Decode Error on CloudWatch message notification",
+			"err", err.Error(),
+			"message", message)
+		return false, messageNotification
+	}
+
+	return len(messageNotification.AlarmName) > 0, messageNotification
+}
+
+func (p *Plugin) createSNSMessageNotificationAttachment(subject string, messageNotification SNSMessageNotification) model.SlackAttachment {
+	p.API.LogDebug("AWSSNS HandleNotification", "MESSAGE", subject)
+	var fields []*model.SlackAttachmentField
+
+	fields = addFields(fields, "AlarmName", messageNotification.AlarmName, true)
+	fields = addFields(fields, "AlarmDescription", messageNotification.AlarmDescription, true)
+	fields = addFields(fields, "AWS Account", messageNotification.AWSAccountID, true)
+	fields = addFields(fields, "Region", messageNotification.Region, true)
+	fields = addFields(fields, "New State", messageNotification.NewStateValue, true)
+	fields = addFields(fields, "Old State", messageNotification.OldStateValue, true)
+	fields = addFields(fields, "New State Reason", messageNotification.NewStateReason, false)
+	switch {
+	case messageNotification.AlarmRule != "":
+		fields = addCompositeAlarmFields(fields, messageNotification)
+	case len(messageNotification.Trigger.Metrics) > 0:
+		fields = addMetricMathAlarmFields(fields, messageNotification.Trigger)
+	default:
+		fields = addFields(fields, "MetricName", messageNotification.Trigger.MetricName, true)
+		fields = addFields(fields, "Namespace", messageNotification.Trigger.Namespace, true)
+		fields = addFields(fields, "StatisticType", messageNotification.Trigger.StatisticType, true)
+		fields = addFields(fields, "Statistic", messageNotification.Trigger.Statistic, true)
+		fields = addFields(fields, "Period", strconv.Itoa(messageNotification.Trigger.Period), true)
+		fields = addFields(fields, "EvaluationPeriods", strconv.Itoa(messageNotification.Trigger.EvaluationPeriods), true)
+		fields = addFields(fields, "ComparisonOperator", messageNotification.Trigger.ComparisonOperator, true)
+		fields = addFields(fields, "Threshold", fmt.Sprintf("%f", messageNotification.Trigger.Threshold), true)
+		fields = addFields(fields, "Dimensions", formatAlarmDimensions(messageNotification.Trigger.Dimensions, "\n"), false)
+	}
+
+	attachment := model.SlackAttachment{
+		Title:     subject,
+		TitleLink: alarmConsoleURL(messageNotification),
+		Fields:    fields,
+		Color:     alarmStateColor(messageNotification.NewStateValue),
+	}
+
+	return attachment
+}
+
+// addCompositeAlarmFields renders the rule of a composite alarm and the child alarms whose state
+// change triggered it.
+func addCompositeAlarmFields(fields []*model.SlackAttachmentField, messageNotification SNSMessageNotification) []*model.SlackAttachmentField {
+	fields = addFields(fields, "Alarm Rule", "`"+messageNotification.AlarmRule+"`", false)
+
+	var children []string
+	for _, child := range messageNotification.TriggeringChildren {
+		name := child.Arn
+		if index := strings.Index(child.Arn, ":alarm:"); index >= 0 {
+			name = child.Arn[index+len(":alarm:"):]
+		}
+		children = append(children, fmt.Sprintf("%s: %s", name, child.State.Value))
+	}
+	return addFields(fields, "Triggering Children", strings.Join(children, "\n"), false)
+}
+
+// addMetricMathAlarmFields renders the metric queries of a metric math or anomaly detection
+// alarm. Alarms comparing against an anomaly detection band have no static threshold.
+func addMetricMathAlarmFields(fields []*model.SlackAttachmentField, trigger AlarmTrigger) []*model.SlackAttachmentField {
+	fields = addFields(fields, "EvaluationPeriods", strconv.Itoa(trigger.EvaluationPeriods), true)
+	fields = addFields(fields, "ComparisonOperator", trigger.ComparisonOperator, true)
+	if trigger.ThresholdMetricID != "" {
+		fields = addFields(fields, "Threshold Metric", trigger.ThresholdMetricID, true)
+	} else {
+		fields = addFields(fields, "Threshold", fmt.Sprintf("%f", trigger.Threshold), true)
+	}
+
+	var queries []string
+	for _, query := range trigger.Metrics {
+		queries = append(queries, formatAlarmMetricQuery(query))
+	}
+	return addFields(fields, "Metric Queries", strings.Join(queries, "\n"), false)
+}
+
+func formatAlarmMetricQuery(query AlarmMetricDataQuery) string {
+	var definition string
+	if query.MetricStat != nil {
+		metric := query.MetricStat.Metric
+		definition = fmt.Sprintf("%s %s %s %ds", metric.Namespace, metric.MetricName, query.MetricStat.Stat, query.MetricStat.Period)
+		if len(metric.Dimensions) > 0 {
+			definition += " (" + formatAlarmDimensions(metric.Dimensions, ", ") + ")"
+		}
+	} else {
+		definition = "`" + query.Expression + "`"
+	}
+
+	line := fmt.Sprintf("**%s**: %s", query.ID, definition)
+	if query.Label != "" {
+		line += fmt.Sprintf(" label: %s", query.Label)
+	}
+	return line + fmt.Sprintf(" ReturnData: %t", query.ReturnData)
+}
+
+func formatAlarmDimensions(dimensions []AlarmDimension, separator string) string {
+	var formatted []string
+	for _, dimension := range dimensions {
+		formatted = append(formatted, fmt.Sprintf("%s: %s", dimension.Name, dimension.Value))
+	}
+	return strings.Join(formatted, separator)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateSNSMessageNotificationAttachment(t *testing.T) {
+	api := &plugintest.API{}
+	api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything).Maybe()
+	p := &Plugin{}
+	p.SetAPI(api)
+
+	for name, test := range map[string]struct {
+		Message        string
+		ExpectedFields map[string]string
+		MissingFields  []string
+	}{
+		"Single metric alarm": {
+			Message: `{"AlarmName": "cpu", "NewStateValue": "ALARM", "Trigger": {"MetricName": "CPUUtilization", "Namespace": "AWS/EC2", "Statistic": "AVERAGE", "Period": 300, "EvaluationPeriods": 1, "ComparisonOperator": "GreaterThanThreshold", "Threshold": 80, "Dimensions": [{"name": "InstanceId", "value": "i-123"}]}}`,
+			ExpectedFields: map[string]string{
+				"MetricName": "CPUUtilization",
+				"Namespace":  "AWS/EC2",
+				"Period":     "300",
+				"Threshold":  "80.000000",
+				"Dimensions": "InstanceId: i-123",
+			},
+			MissingFields: []string{"Metric Queries", "Alarm Rule"},
+		},
+		"Metric math alarm": {
+			Message: `{"AlarmName": "errors", "NewStateValue": "ALARM", "Trigger": {"EvaluationPeriods": 1, "ComparisonOperator": "GreaterThanThreshold", "Threshold": 5, "Metrics": [
+				{"Id": "e1", "Expression": "100 * m1 / m2", "Label": "Error rate", "ReturnData": true},
+				{"Id": "m1", "MetricStat": {"Metric": {"Namespace": "AWS/ApplicationELB", "MetricName": "HTTPCode_Target_5XX_Count", "Dimensions": [{"name": "LoadBalancer", "value": "app/web"}]}, "Period": 60, "Stat": "Sum"}, "ReturnData": false},
+				{"Id": "m2", "MetricStat": {"Metric": {"Namespace": "AWS/ApplicationELB", "MetricName": "RequestCount"}, "Period": 60, "Stat": "Sum"}, "ReturnData": false}
+			]}}`,
+			ExpectedFields: map[string]string{
+				"Threshold": "5.000000",
+				"Metric Queries": "**e1**: `100 * m1 / m2` label: Error rate ReturnData: true\n" +
+					"**m1**: AWS/ApplicationELB HTTPCode_Target_5XX_Count Sum 60s (LoadBalancer: app/web) ReturnData: false\n" +
+					"**m2**: AWS/ApplicationELB RequestCount Sum 60s ReturnData: false",
+			},
+			MissingFields: []string{"MetricName", "Period", "Dimensions"},
+		},
+		"Anomaly detection alarm": {
+			Message: `{"AlarmName": "latency", "NewStateValue": "ALARM", "Trigger": {"EvaluationPeriods": 2, "ComparisonOperator": "LessThanLowerOrGreaterThanUpperThreshold", "ThresholdMetricId": "ad1", "Metrics": [
+				{"Id": "m1", "MetricStat": {"Metric": {"Namespace": "AWS/EC2", "MetricName": "CPUUtilization"}, "Period": 300, "Stat": "Average"}, "ReturnData": true},
+				{"Id": "ad1", "Expression": "ANOMALY_DETECTION_BAND(m1, 2)", "ReturnData": true}
+			]}}`,
+			ExpectedFields: map[string]string{
+				"Threshold Metric":   "ad1",
+				"ComparisonOperator": "LessThanLowerOrGreaterThanUpperThreshold",
+			},
+			MissingFields: []string{"Threshold"},
+		},
+		"Composite alarm": {
+			Message: `{"AlarmName": "service-down", "NewStateValue": "ALARM", "AlarmRule": "ALARM(cpu) OR ALARM(errors)", "TriggeringChildren": [
+				{"Arn": "arn:aws:cloudwatch:us-east-1:123456789012:alarm:errors", "State": {"Value": "ALARM", "Timestamp": "2026-10-17T10:00:00.000+0000"}}
+			]}`,
+			ExpectedFields: map[string]string{
+				"Alarm Rule":          "`ALARM(cpu) OR ALARM(errors)`",
+				"Triggering Children": "errors: ALARM",
+			},
+			MissingFields: []string{"MetricName", "Period", "Threshold", "EvaluationPeriods"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var messageNotification SNSMessageNotification
+			require.NoError(t, json.Unmarshal([]byte(test.Message), &messageNotification))
+
+			attachment := p.createSNSMessageNotificationAttachment("ALARM", messageNotification)
+			fields := renderFields(&attachment)
+			for title, value := range test.ExpectedFields {
+				assert.Equal(t, value, fields[title], title)
+			}
+			for _, title := range test.MissingFields {
+				assert.NotContains(t, fields, title)
+			}
+		})
+	}
+}
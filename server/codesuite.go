@@ -0,0 +1,317 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"github.com/mattermost/mattermost-plugin-aws-SNS/server/arn"
+	"github.com/pkg/errors"
+)
+
+const (
+	// pipelineThreadTTL is how long after its last event a pipeline execution keeps posting into
+	// the same thread.
+	pipelineThreadTTL = 7 * 24 * time.Hour
+
+	pipelineStateFieldTitle = "Pipeline State"
+
+	codePipelineExecutionDetailType = "CodePipeline Pipeline Execution State Change"
+	codePipelineStageDetailType     = "CodePipeline Stage Execution State Change"
+	codePipelineActionDetailType    = "CodePipeline Action Execution State Change"
+	codeBuildDetailType             = "CodeBuild Build State Change"
+	codeDeployDetailType            = "CodeDeploy Deployment State-change Notification"
+)
+
+// codePipelineEvent is a CodePipeline state change along with the EventBridge event that carried it.
+type codePipelineEvent struct {
+	Event  *EventBridgeEvent
+	Change CodePipelineStateChange
+}
+
+// codePipelineParser handles pipeline execution, stage and action state changes. The events of
+// a pipeline execution are threaded under the post of its first event.
+type codePipelineParser struct {
+	p *Plugin
+}
+
+func (c *codePipelineParser) Name() string  { return "codepipeline" }
+func (c *codePipelineParser) Priority() int { return 930 }
+
+func (c *codePipelineParser) Detect(notification *SNSNotification) bool {
+	_, ok := isEventBridgeEvent(notification.Message, "aws.codepipeline", codePipelineExecutionDetailType, codePipelineStageDetailType, codePipelineActionDetailType)
+	return ok
+}
+
+func (c *codePipelineParser) Parse(notification *SNSNotification) (interface{}, error) {
+	event, ok := isEventBridgeEvent(notification.Message, "aws.codepipeline", codePipelineExecutionDetailType, codePipelineStageDetailType, codePipelineActionDetailType)
+	if !ok {
+		return nil, errors.New("message is not a CodePipeline state change")
+	}
+
+	var change CodePipelineStateChange
+	if err := json.Unmarshal(event.Detail, &change); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal CodePipeline state change")
+	}
+	if change.Pipeline == "" || change.ExecutionID == "" {
+		return nil, errors.New("CodePipeline state change has no pipeline or execution id")
+	}
+
+	return &codePipelineEvent{Event: event, Change: change}, nil
+}
+
+func (c *codePipelineParser) Render(notification *SNSNotification, event interface{}) []*model.SlackAttachment {
+	return []*model.SlackAttachment{c.p.createCodePipelineAttachment(event.(*codePipelineEvent))}
+}
+
+func (c *codePipelineParser) Post(event interface{}, attachments []*model.SlackAttachment, channels []*TeamChannel) {
+	pipelineEvent := event.(*codePipelineEvent)
+	for _, channel := range channels {
+		if err := c.p.postCodePipelineEvent(pipelineEvent, attachments, channel); err != nil {
+			c.p.API.LogError("AWSSNS unable to post the CodePipeline event", "channel_id", channel.ChannelID, "execution_id", pipelineEvent.Change.ExecutionID, "err", err.Error())
+		}
+	}
+}
+
+func (p *Plugin) createCodePipelineAttachment(event *codePipelineEvent) *model.SlackAttachment {
+	change := event.Change
+	p.API.LogDebug("AWSSNS HandleNotification CodePipeline Event", "PIPELINE", change.Pipeline, "STATE", change.State)
+
+	var fields []*model.SlackAttachmentField
+	fields = addFields(fields, "Pipeline", change.Pipeline, true)
+	fields = addFields(fields, "State", change.State, true)
+
+	var title string
+	switch event.Event.DetailType {
+	case codePipelineActionDetailType:
+		title = fmt.Sprintf("CodePipeline action %s %s", change.Action, change.State)
+		fields = addFields(fields, "Stage", change.Stage, true)
+		fields = addFields(fields, "Action", change.Action, true)
+		if change.Type != nil {
+			fields = addFields(fields, "Provider", change.Type.Provider, true)
+		}
+	case codePipelineStageDetailType:
+		title = fmt.Sprintf("CodePipeline stage %s %s", change.Stage, change.State)
+		fields = addFields(fields, "Stage", change.Stage, true)
+	default:
+		title = fmt.Sprintf("CodePipeline %s %s", change.Pipeline, change.State)
+	}
+
+	fields = addFields(fields, "Execution ID", change.ExecutionID, false)
+	fields = addFields(fields, "AWS Account", event.Event.Account, true)
+	fields = addFields(fields, "Region", event.Event.Region, true)
+
+	return &model.SlackAttachment{
+		Title: title,
+		TitleLink: fmt.Sprintf("%s/codesuite/codepipeline/pipelines/%s/executions/%s/timeline?region=%s",
+			consoleBaseURL(eventPartition(event.Event)), url.PathEscape(change.Pipeline), url.PathEscape(change.ExecutionID), url.QueryEscape(event.Event.Region)),
+		Fields: fields,
+		Color:  outcomeColor(change.State),
+	}
+}
+
+// postCodePipelineEvent posts the event into the thread of its pipeline execution. Pipeline
+// level state changes are also shown on the post that started the thread.
+func (p *Plugin) postCodePipelineEvent(event *codePipelineEvent, attachments []*model.SlackAttachment, channel *TeamChannel) error {
+	change := event.Change
+	key := eventThreadKey(channel.ChannelID, "codepipeline", change.Pipeline+"/"+change.ExecutionID)
+	root, isReply, err := p.postThreaded(channel, key, attachments, pipelineThreadTTL)
+	if err != nil || !isReply || event.Event.DetailType != codePipelineExecutionDetailType {
+		return err
+	}
+
+	rootAttachments := root.Attachments()
+	if len(rootAttachments) == 0 {
+		return nil
+	}
+	rootAttachments[0].Color = outcomeColor(change.State)
+	setAttachmentField(rootAttachments[0], pipelineStateFieldTitle, change.State)
+
+	model.ParseSlackAttachment(root, rootAttachments)
+	if _, appErr := p.API.UpdatePost(root); appErr != nil {
+		p.API.LogWarn("AWSSNS unable to update the CodePipeline thread", "post_id", root.Id, "err", appErr.Error())
+	}
+	return nil
+}
+
+// codeBuildEvent is a CodeBuild build state change along with the EventBridge event that carried it.
+type codeBuildEvent struct {
+	Event  *EventBridgeEvent
+	Change CodeBuildStateChange
+}
+
+// codeBuildParser handles CodeBuild build state changes.
+type codeBuildParser struct {
+	p *Plugin
+}
+
+func (c *codeBuildParser) Name() string  { return "codebuild" }
+func (c *codeBuildParser) Priority() int { return 931 }
+
+func (c *codeBuildParser) Detect(notification *SNSNotification) bool {
+	_, ok := isEventBridgeEvent(notification.Message, "aws.codebuild", codeBuildDetailType)
+	return ok
+}
+
+func (c *codeBuildParser) Parse(notification *SNSNotification) (interface{}, error) {
+	event, ok := isEventBridgeEvent(notification.Message, "aws.codebuild", codeBuildDetailType)
+	if !ok {
+		return nil, errors.New("message is not a CodeBuild build state change")
+	}
+
+	var change CodeBuildStateChange
+	if err := json.Unmarshal(event.Detail, &change); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal CodeBuild build state change")
+	}
+	if change.ProjectName == "" || change.BuildStatus == "" {
+		return nil, errors.New("CodeBuild build state change has no project or status")
+	}
+
+	return &codeBuildEvent{Event: event, Change: change}, nil
+}
+
+func (c *codeBuildParser) Render(notification *SNSNotification, event interface{}) []*model.SlackAttachment {
+	return []*model.SlackAttachment{c.p.createCodeBuildAttachment(event.(*codeBuildEvent))}
+}
+
+func (p *Plugin) createCodeBuildAttachment(event *codeBuildEvent) *model.SlackAttachment {
+	change := event.Change
+	p.API.LogDebug("AWSSNS HandleNotification CodeBuild Event", "PROJECT", change.ProjectName, "STATUS", change.BuildStatus)
+
+	var fields []*model.SlackAttachmentField
+	fields = addFields(fields, "Project", change.ProjectName, true)
+	fields = addFields(fields, "Status", change.BuildStatus, true)
+	if change.AdditionalInformation.BuildNumber > 0 {
+		fields = addFields(fields, "Build Number", strconv.Itoa(change.AdditionalInformation.BuildNumber), true)
+	}
+	fields = addFields(fields, "Phase", codeBuildPhase(change), true)
+	if change.CurrentPhaseContext != "" && change.CurrentPhaseContext != "[]" {
+		fields = addFields(fields, "Phase Context", change.CurrentPhaseContext, false)
+	}
+	if change.AdditionalInformation.Initiator != "" {
+		fields = addFields(fields, "Initiator", change.AdditionalInformation.Initiator, true)
+	}
+	fields = addFields(fields, "AWS Account", event.Event.Account, true)
+	fields = addFields(fields, "Region", event.Event.Region, true)
+	if logs := change.AdditionalInformation.Logs.DeepLink; logs != "" {
+		fields = addFields(fields, "Logs", fmt.Sprintf("[%s](%s)", change.AdditionalInformation.Logs.StreamName, logs), false)
+	}
+
+	// build ids have the form arn:aws:codebuild:region:account-id:build/project-name:build-uuid
+	buildID := change.BuildID[strings.LastIndex(change.BuildID, "/")+1:]
+
+	return &model.SlackAttachment{
+		Title: fmt.Sprintf("CodeBuild %s %s", change.ProjectName, change.BuildStatus),
+		TitleLink: fmt.Sprintf("%s/codesuite/codebuild/projects/%s/build/%s/?region=%s",
+			consoleBaseURL(eventPartition(event.Event)), url.PathEscape(change.ProjectName), url.PathEscape(buildID), url.QueryEscape(event.Event.Region)),
+		Fields: fields,
+		Color:  outcomeColor(change.BuildStatus),
+	}
+}
+
+// codeBuildPhase returns the phase that failed when the build did not succeed, otherwise the
+// current phase.
+func codeBuildPhase(change CodeBuildStateChange) string {
+	for _, phase := range change.AdditionalInformation.Phases {
+		if phase.PhaseStatus != "" && phase.PhaseStatus != "SUCCEEDED" {
+			return fmt.Sprintf("%s (%s)", phase.PhaseType, phase.PhaseStatus)
+		}
+	}
+	return change.CurrentPhase
+}
+
+// codeDeployEvent is a CodeDeploy deployment state change along with the EventBridge event that
+// carried it.
+type codeDeployEvent struct {
+	Event  *EventBridgeEvent
+	Change CodeDeployStateChange
+}
+
+// codeDeployParser handles CodeDeploy deployment state changes.
+type codeDeployParser struct {
+	p *Plugin
+}
+
+func (c *codeDeployParser) Name() string  { return "codedeploy" }
+func (c *codeDeployParser) Priority() int { return 932 }
+
+func (c *codeDeployParser) Detect(notification *SNSNotification) bool {
+	_, ok := isEventBridgeEvent(notification.Message, "aws.codedeploy", codeDeployDetailType)
+	return ok
+}
+
+func (c *codeDeployParser) Parse(notification *SNSNotification) (interface{}, error) {
+	event, ok := isEventBridgeEvent(notification.Message, "aws.codedeploy", codeDeployDetailType)
+	if !ok {
+		return nil, errors.New("message is not a CodeDeploy deployment state change")
+	}
+
+	var change CodeDeployStateChange
+	if err := json.Unmarshal(event.Detail, &change); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal CodeDeploy deployment state change")
+	}
+	if change.DeploymentID == "" || change.State == "" {
+		return nil, errors.New("CodeDeploy deployment state change has no deployment id or state")
+	}
+
+	return &codeDeployEvent{Event: event, Change: change}, nil
+}
+
+func (c *codeDeployParser) Render(notification *SNSNotification, event interface{}) []*model.SlackAttachment {
+	return []*model.SlackAttachment{c.p.createCodeDeployAttachment(event.(*codeDeployEvent))}
+}
+
+func (p *Plugin) createCodeDeployAttachment(event *codeDeployEvent) *model.SlackAttachment {
+	change := event.Change
+	p.API.LogDebug("AWSSNS HandleNotification CodeDeploy Event", "DEPLOYMENT", change.DeploymentID, "STATE", change.State)
+
+	region := change.Region
+	if region == "" {
+		region = event.Event.Region
+	}
+
+	var fields []*model.SlackAttachmentField
+	fields = addFields(fields, "Application", change.Application, true)
+	fields = addFields(fields, "Deployment Group", change.DeploymentGroup, true)
+	fields = addFields(fields, "State", change.State, true)
+	fields = addFields(fields, "Deployment ID", change.DeploymentID, true)
+	fields = addFields(fields, "AWS Account", event.Event.Account, true)
+	fields = addFields(fields, "Region", region, true)
+
+	return &model.SlackAttachment{
+		Title: fmt.Sprintf("CodeDeploy %s %s", change.Application, change.State),
+		TitleLink: fmt.Sprintf("%s/codesuite/codedeploy/deployments/%s?region=%s",
+			consoleBaseURL(eventPartition(event.Event)), url.PathEscape(change.DeploymentID), url.QueryEscape(region)),
+		Fields: fields,
+		Color:  outcomeColor(change.State),
+	}
+}
+
+// outcomeColor colors the states of pipelines, builds and deployments by their outcome.
+func outcomeColor(state string) string {
+	switch state {
+	case "SUCCEEDED", "SUCCESS":
+		return colorGreen
+	case "FAILED", "FAILURE", "FAULT", "TIMED_OUT":
+		return colorRed
+	case "CANCELED", "STOPPED", "STOPPING", "STOP", "SUPERSEDED", "ABANDONED":
+		return colorYellow
+	default:
+		return colorBlue
+	}
+}
+
+// eventPartition returns the partition of the first ARN in the resources of the event.
+func eventPartition(event *EventBridgeEvent) string {
+	for _, resource := range event.Resources {
+		if parsed, err := arn.Parse(resource); err == nil {
+			return parsed.Partition
+		}
+	}
+	return "aws"
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	codePipelineActionMessage = `{
+		"account": "123456789012",
+		"detailType": "CodePipeline Action Execution State Change",
+		"region": "us-east-1",
+		"source": "aws.codepipeline",
+		"time": "2020-01-24T22:03:07Z",
+		"notificationRuleArn": "arn:aws:codestar-notifications:us-east-1:123456789012:notificationrule/a69c62c21EXAMPLE",
+		"detail": {"pipeline": "my-pipeline", "execution-id": "c5a8f4a3-2f84-4ff9-9a41-EXAMPLE", "stage": "Deploy", "action": "Deploy", "state": "FAILED", "type": {"owner": "AWS", "category": "Deploy", "provider": "CodeDeploy", "version": "1"}},
+		"resources": ["arn:aws:codepipeline:us-east-1:123456789012:my-pipeline"]
+	}`
+	codePipelineExecutionMessage = `{
+		"version": "0",
+		"id": "01234567-EXAMPLE",
+		"detail-type": "CodePipeline Pipeline Execution State Change",
+		"source": "aws.codepipeline",
+		"account": "123456789012",
+		"time": "2020-01-24T22:03:44Z",
+		"region": "us-east-1",
+		"resources": ["arn:aws:codepipeline:us-east-1:123456789012:my-pipeline"],
+		"detail": {"pipeline": "my-pipeline", "execution-id": "c5a8f4a3-2f84-4ff9-9a41-EXAMPLE", "state": "FAILED", "version": 1}
+	}`
+	codeBuildMessage = `{
+		"version": "0",
+		"id": "bfdc1220-60ff-44bb-8cce-EXAMPLE",
+		"detail-type": "CodeBuild Build State Change",
+		"source": "aws.codebuild",
+		"account": "123456789012",
+		"time": "2017-09-01T16:14:28Z",
+		"region": "us-west-2",
+		"resources": ["arn:aws:codebuild:us-west-2:123456789012:build/my-project:1a2b3c4d-5678-90ab-cdef-EXAMPLE"],
+		"detail": {
+			"build-status": "FAILED",
+			"project-name": "my-project",
+			"build-id": "arn:aws:codebuild:us-west-2:123456789012:build/my-project:1a2b3c4d-5678-90ab-cdef-EXAMPLE",
+			"current-phase": "COMPLETED",
+			"current-phase-context": "[]",
+			"additional-information": {
+				"initiator": "codepipeline/my-pipeline",
+				"build-number": 12,
+				"logs": {"group-name": "/aws/codebuild/my-project", "stream-name": "1a2b3c4d", "deep-link": "https://console.aws.amazon.com/cloudwatch/home?region=us-west-2#logEvent:group=/aws/codebuild/my-project;stream=1a2b3c4d"},
+				"phases": [{"phase-type": "SUBMITTED", "phase-status": "SUCCEEDED"}, {"phase-type": "BUILD", "phase-status": "FAILED"}, {"phase-type": "COMPLETED"}]
+			}
+		}
+	}`
+	codeDeployMessage = `{
+		"version": "0",
+		"id": "c071bfbf-83c4-49ca-a6ff-EXAMPLE",
+		"detail-type": "CodeDeploy Deployment State-change Notification",
+		"source": "aws.codedeploy",
+		"account": "123456789012",
+		"time": "2016-06-30T22:06:31Z",
+		"region": "us-west-2",
+		"resources": ["arn:aws:codedeploy:us-west-2:123456789012:deploymentgroup:myApplication/myDeploymentGroup"],
+		"detail": {"region": "us-west-2", "deploymentId": "d-456789012", "instanceGroupId": "9fd2fbef-2157-40d8-91e7-EXAMPLE", "deploymentGroup": "myDeploymentGroup", "state": "SUCCESS", "application": "myApplication"}
+	}`
+)
+
+func renderFields(attachment *model.SlackAttachment) map[string]string {
+	values := map[string]string{}
+	for _, field := range attachment.Fields {
+		values[field.Title] = field.Value.(string)
+	}
+	return values
+}
+
+func TestCodeSuiteParsers(t *testing.T) {
+	api := &plugintest.API{}
+	api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+	p := &Plugin{}
+	p.SetAPI(api)
+
+	t.Run("CodePipeline action from CodeStar Notifications", func(t *testing.T) {
+		parser := &codePipelineParser{p: p}
+		notification := &SNSNotification{Message: codePipelineActionMessage}
+		require.True(t, parser.Detect(notification))
+
+		event, err := parser.Parse(notification)
+		require.NoError(t, err)
+		attachment := parser.Render(notification, event)[0]
+
+		assert.Equal(t, "CodePipeline action Deploy FAILED", attachment.Title)
+		assert.Equal(t, "https://console.aws.amazon.com/codesuite/codepipeline/pipelines/my-pipeline/executions/c5a8f4a3-2f84-4ff9-9a41-EXAMPLE/timeline?region=us-east-1", attachment.TitleLink)
+		assert.Equal(t, colorRed, attachment.Color)
+		values := renderFields(attachment)
+		assert.Equal(t, "Deploy", values["Stage"])
+		assert.Equal(t, "CodeDeploy", values["Provider"])
+	})
+
+	t.Run("CodeBuild build", func(t *testing.T) {
+		parser := &codeBuildParser{p: p}
+		notification := &SNSNotification{Message: codeBuildMessage}
+		require.True(t, parser.Detect(notification))
+
+		event, err := parser.Parse(notification)
+		require.NoError(t, err)
+		attachment := parser.Render(notification, event)[0]
+
+		assert.Equal(t, "CodeBuild my-project FAILED", attachment.Title)
+		assert.Equal(t, "https://console.aws.amazon.com/codesuite/codebuild/projects/my-project/build/my-project:1a2b3c4d-5678-90ab-cdef-EXAMPLE/?region=us-west-2", attachment.TitleLink)
+		assert.Equal(t, colorRed, attachment.Color)
+		values := renderFields(attachment)
+		assert.Equal(t, "BUILD (FAILED)", values["Phase"])
+		assert.Equal(t, "12", values["Build Number"])
+		assert.Equal(t, "[1a2b3c4d](https://console.aws.amazon.com/cloudwatch/home?region=us-west-2#logEvent:group=/aws/codebuild/my-project;stream=1a2b3c4d)", values["Logs"])
+		assert.NotContains(t, values, "Phase Context")
+	})
+
+	t.Run("CodeDeploy deployment", func(t *testing.T) {
+		parser := &codeDeployParser{p: p}
+		notification := &SNSNotification{Message: codeDeployMessage}
+		require.True(t, parser.Detect(notification))
+
+		event, err := parser.Parse(notification)
+		require.NoError(t, err)
+		attachment := parser.Render(notification, event)[0]
+
+		assert.Equal(t, "CodeDeploy myApplication SUCCESS", attachment.Title)
+		assert.Equal(t, "https://console.aws.amazon.com/codesuite/codedeploy/deployments/d-456789012?region=us-west-2", attachment.TitleLink)
+		assert.Equal(t, colorGreen, attachment.Color)
+		assert.Equal(t, "myDeploymentGroup", renderFields(attachment)["Deployment Group"])
+	})
+}
+
+func TestCodePipelineThread(t *testing.T) {
+	channel := &TeamChannel{ChannelID: "channelId1"}
+	key := eventThreadKey(channel.ChannelID, "codepipeline", "my-pipeline/c5a8f4a3-2f84-4ff9-9a41-EXAMPLE")
+
+	api := &plugintest.API{}
+	api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+	p := &Plugin{BotUserID: "botId"}
+	p.SetAPI(api)
+	parser := &codePipelineParser{p: p}
+
+	root := &model.Post{Id: "rootId", ChannelId: channel.ChannelID}
+	model.ParseSlackAttachment(root, []*model.SlackAttachment{{Title: "CodePipeline my-pipeline STARTED", Color: colorBlue}})
+
+	thread, err := json.Marshal(&EventThread{RootPostID: "rootId"})
+	require.NoError(t, err)
+	api.On("KVGet", key).Return(thread, nil)
+	api.On("GetPost", "rootId").Return(root, nil)
+	api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+		return post.RootId == "rootId"
+	})).Return(&model.Post{Id: "replyId"}, nil).Twice()
+	api.On("KVSetWithExpiry", key, thread, int64(pipelineThreadTTL.Seconds())).Return(nil)
+	api.On("UpdatePost", mock.MatchedBy(func(post *model.Post) bool {
+		attachment := post.Attachments()[0]
+		return attachment.Color == colorRed && renderFields(attachment)[pipelineStateFieldTitle] == "FAILED"
+	})).Return(root, nil).Once()
+
+	for _, message := range []string{codePipelineActionMessage, codePipelineExecutionMessage} {
+		notification := &SNSNotification{Message: message}
+		event, err := parser.Parse(notification)
+		require.NoError(t, err)
+		parser.Post(event, parser.Render(notification, event), []*TeamChannel{channel})
+	}
+
+	api.AssertExpectations(t)
+}
@@ -1,9 +1,10 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/mattermost/mattermost/server/public/plugin"
@@ -25,7 +26,7 @@ func (p *Plugin) registerCommands() error {
 		DisplayName:          "AWS SNS",
 		AutoComplete:         true,
 		AutoCompleteHint:     "[command]",
-		AutoCompleteDesc:     "Available commands: list-topics",
+		AutoCompleteDesc:     "Available commands: list-topics, token, rotate-token, pause, resume",
 		AutocompleteData:     getAutoCompleteData(),
 		AutocompleteIconData: iconData,
 	})
@@ -46,6 +47,11 @@ func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*mo
 		action = splitCmd[1]
 	}
 
+	parameters := []string{}
+	if len(splitCmd) > 2 {
+		parameters = splitCmd[2:]
+	}
+
 	if cmd != awsSNSCmd {
 		return &model.CommandResponse{
 			ResponseType: model.CommandResponseTypeEphemeral,
@@ -56,6 +62,14 @@ func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*mo
 	switch action {
 	case "list-topics":
 		return p.listTopicsToChannel(args.ChannelId), nil
+	case "token":
+		return p.executeTokenCommand(args, parameters, false), nil
+	case "rotate-token":
+		return p.executeTokenCommand(args, parameters, true), nil
+	case "pause":
+		return p.executePauseCommand(args, parameters, true), nil
+	case "resume":
+		return p.executePauseCommand(args, parameters, false), nil
 	default:
 		return &model.CommandResponse{
 			ResponseType: model.CommandResponseTypeEphemeral,
@@ -64,44 +78,197 @@ func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*mo
 	}
 }
 
-// listTopicsToChannel Lists topics subscribed to the channel
+// listTopicsToChannel Lists topics subscribed to the channel, with who confirmed each
+// subscription and the messages it received
 func (p *Plugin) listTopicsToChannel(channelID string) *model.CommandResponse {
-	var topics SNSTopics
-	val, err := p.API.KVGet(topicsListPrefix + channelID)
+	topics, err := p.getTopics(channelID)
 	if err != nil {
-		p.API.LogError("Failed to Get from KV Store")
+		p.API.LogError("AWSSNS unable to get the topics of the channel", "err", err.Error())
 		return &model.CommandResponse{
 			ResponseType: model.CommandResponseTypeEphemeral,
 			Text:         err.Error(),
 		}
 	}
-	if val == nil {
+	if len(topics.Subscriptions) == 0 {
 		return &model.CommandResponse{
 			ResponseType: model.CommandResponseTypeEphemeral,
 			Text:         "No Topics are subscribed by the configured channel",
 		}
 	}
-	unMarshalErr := json.Unmarshal(val, &topics)
-	if unMarshalErr != nil {
-		p.API.LogError("Failed to Unmarshal")
+
+	var keys []string
+	for key := range topics.Subscriptions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	resp := "The following SNS topics are subscribed by the configured channel\n"
+	for _, key := range keys {
+		resp += p.formatSubscription(channelID, topics.Subscriptions[key])
+	}
+	return &model.CommandResponse{
+		ResponseType: model.CommandResponseTypeInChannel,
+		Text:         resp,
+	}
+}
+
+// formatSubscription describes a subscription as a list item of list-topics.
+func (p *Plugin) formatSubscription(channelID string, subscription *Subscription) string {
+	state := "active"
+	if subscription.Paused {
+		state = "paused"
+	}
+
+	topicArn, err := parseTopicArn(subscription.TopicArn)
+	if err != nil {
+		// topics stored before they were keyed by ARN only have a name
+		return fmt.Sprintf("* **%s**, %s\n", subscription.TopicArn, state)
+	}
+
+	resp := fmt.Sprintf("* **%s** (account %s, %s), %s: `%s`\n", topicArn.Resource, topicArn.AccountID, topicArn.Region, state, subscription.TopicArn)
+	if subscription.ConfirmedBy != "" {
+		confirmedBy := subscription.ConfirmedBy
+		if user, appErr := p.API.GetUser(subscription.ConfirmedBy); appErr == nil {
+			confirmedBy = "@" + user.Username
+		}
+		resp += fmt.Sprintf("  Confirmed by %s on %s.", confirmedBy, formatMillis(subscription.ConfirmedAt))
+	} else {
+		resp += "  Not confirmed from Mattermost."
+	}
+	stats, err := p.getSubscriptionStats(channelID, subscription.TopicArn)
+	switch {
+	case err != nil:
+		p.API.LogWarn("AWSSNS unable to get the message counts of the topic", "topic_arn", subscription.TopicArn, "err", err.Error())
+	case stats.MessageCount > 0:
+		resp += fmt.Sprintf(" %d messages, the last one on %s.", stats.MessageCount, formatMillis(stats.LastMessageAt))
+	default:
+		resp += " No messages received yet."
+	}
+	resp += "\n"
+	if subscription.SubscriptionArn != "" {
+		resp += fmt.Sprintf("  Subscription: `%s`\n", subscription.SubscriptionArn)
+	}
+	return resp
+}
+
+func formatMillis(millis int64) string {
+	return time.UnixMilli(millis).UTC().Format(time.RFC1123)
+}
+
+// executePauseCommand pauses or resumes the subscription of the channel to a topic. Messages of
+// a paused subscription are counted but not posted.
+func (p *Plugin) executePauseCommand(args *model.CommandArgs, parameters []string, paused bool) *model.CommandResponse {
+	if err := p.checkAllowedUsers(args.UserId); err != nil {
 		return &model.CommandResponse{
 			ResponseType: model.CommandResponseTypeEphemeral,
-			Text:         unMarshalErr.Error(),
+			Text:         err.Error(),
 		}
 	}
-	resp := "The following SNS topics are subscribed by the configured channel\n"
-	for topicName := range topics.Topics {
-		resp = resp + "* " + topicName + "\n"
+	if len(parameters) != 1 {
+		return &model.CommandResponse{
+			ResponseType: model.CommandResponseTypeEphemeral,
+			Text:         "Please give the name or ARN of the topic",
+		}
+	}
+
+	topicArn, err := p.setSubscriptionPaused(args.ChannelId, parameters[0], paused)
+	if err != nil {
+		return &model.CommandResponse{
+			ResponseType: model.CommandResponseTypeEphemeral,
+			Text:         err.Error(),
+		}
+	}
+
+	resp := fmt.Sprintf("Notifications of `%s` are posted again.", topicArn)
+	if paused {
+		resp = fmt.Sprintf("Notifications of `%s` are paused. Run `/%s resume %s` to post them again.", topicArn, awsSNSCmd, parameters[0])
 	}
 	return &model.CommandResponse{
-		ResponseType: model.CommandResponseTypeInChannel,
+		ResponseType: model.CommandResponseTypeEphemeral,
+		Text:         resp,
+	}
+}
+
+// executeTokenCommand shows, or rotates, the token of the channel or of a single topic and
+// returns the matching subscription URL
+func (p *Plugin) executeTokenCommand(args *model.CommandArgs, parameters []string, rotate bool) *model.CommandResponse {
+	if err := p.checkAllowedUsers(args.UserId); err != nil {
+		return &model.CommandResponse{
+			ResponseType: model.CommandResponseTypeEphemeral,
+			Text:         err.Error(),
+		}
+	}
+
+	channel := p.getTeamChannelByID(args.ChannelId)
+	if channel == nil {
+		return &model.CommandResponse{
+			ResponseType: model.CommandResponseTypeEphemeral,
+			Text:         "This channel is not configured to receive AWS SNS notifications",
+		}
+	}
+
+	topic := ""
+	if len(parameters) > 0 {
+		topicArn, err := parseTopicArn(parameters[0])
+		if err != nil {
+			return &model.CommandResponse{
+				ResponseType: model.CommandResponseTypeEphemeral,
+				Text:         fmt.Sprintf("%s. Use the ARN of the topic, e.g. arn:aws:sns:us-east-1:123456789012:alerts", err.Error()),
+			}
+		}
+		topic = topicArn.String()
+	}
+
+	var token *WebhookToken
+	var err error
+	if rotate {
+		token, err = p.rotateWebhookToken(channel.ChannelID, topic)
+	} else {
+		token, err = p.getOrCreateWebhookToken(channel.ChannelID, topic)
+	}
+	if err != nil {
+		p.API.LogError("Failed to get the webhook token", "err", err.Error())
+		return &model.CommandResponse{
+			ResponseType: model.CommandResponseTypeEphemeral,
+			Text:         err.Error(),
+		}
+	}
+
+	resp := "Use the following URL for the AWS SNS HTTPS subscription"
+	if topic != "" {
+		resp += fmt.Sprintf(" of topic %s", topic)
+	}
+	resp += fmt.Sprintf(":\n`%s`", p.subscriptionURL(channel, topic, token.Token))
+	if rotate && token.PreviousToken != "" {
+		resp += fmt.Sprintf("\nThe previous token remains valid until %s.", time.UnixMilli(token.PreviousExpiresAt).UTC().Format(time.RFC1123))
+	}
+
+	return &model.CommandResponse{
+		ResponseType: model.CommandResponseTypeEphemeral,
 		Text:         resp,
 	}
 }
 
 func getAutoCompleteData() *model.AutocompleteData {
-	aws := model.NewAutocompleteData(awsSNSCmd, "[command]", "Available commands: list-topics")
+	aws := model.NewAutocompleteData(awsSNSCmd, "[command]", "Available commands: list-topics, token, rotate-token, pause, resume")
 	listTopics := model.NewAutocompleteData("list-topics", "", "Lists Topics which are subscribed to the channel")
 	aws.AddCommand(listTopics)
+
+	token := model.NewAutocompleteData("token", "[topic-arn]", "Shows the subscription URL of the channel, or of a single topic")
+	token.AddTextArgument("ARN of the SNS topic that gets its own token", "[topic-arn]", "")
+	aws.AddCommand(token)
+
+	rotateToken := model.NewAutocompleteData("rotate-token", "[topic-arn]", "Generates a new token for the channel, or for a single topic")
+	rotateToken.AddTextArgument("ARN of the SNS topic whose token is rotated", "[topic-arn]", "")
+	aws.AddCommand(rotateToken)
+
+	pause := model.NewAutocompleteData("pause", "[topic]", "Stops posting the notifications of a topic subscribed by the channel")
+	pause.AddTextArgument("Name or ARN of the SNS topic", "[topic]", "")
+	aws.AddCommand(pause)
+
+	resume := model.NewAutocompleteData("resume", "[topic]", "Posts the notifications of a paused topic again")
+	resume.AddTextArgument("Name or ARN of the SNS topic", "[topic]", "")
+	aws.AddCommand(resume)
+
 	return aws
 }
@@ -18,9 +18,26 @@ import (
 // If you add non-reference types to your configuration struct, be sure to rewrite Clone as a deep
 // copy appropriate for your types.
 type configuration struct {
-	TeamChannel    string
-	AllowedUserIds string
-	Token          string
+	TeamChannel               string
+	AllowedUserIds            string
+	Token                     string
+	AcceptGlobalToken         bool
+	TokenRotationOverlapHours int
+	MaxMessageAgeMinutes      int
+	TopicRoutes               string
+	NotificationRules         string
+	NotificationRulesDryRun   bool
+	AlarmResponders           string
+	DisabledParsers           string
+	AutoScalingBurstMinutes   int
+	SESSummaryMinutes         int
+
+	// topicRoutes, notificationRules, alarmResponders and disabledParsers are computed from the
+	// settings of the same name in OnConfigurationChange.
+	topicRoutes       []*TopicRoute
+	notificationRules []*NotificationRule
+	alarmResponders   map[string][]string
+	disabledParsers   map[string]bool
 }
 
 // Clone shallow copies the configuration. Your implementation may require a deep copy if
@@ -80,7 +97,31 @@ func (p *Plugin) OnConfigurationChange() error {
 		return errors.Wrap(err, "failed to load plugin configuration")
 	}
 
+	topicRoutes, err := parseTopicRoutes(configuration.TopicRoutes)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse topic routes")
+	}
+	configuration.topicRoutes = topicRoutes
+
+	notificationRules, err := parseNotificationRules(configuration.NotificationRules)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse notification rules")
+	}
+	configuration.notificationRules = notificationRules
+
+	alarmResponders, err := parseAlarmResponders(configuration.AlarmResponders)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse alarm responders")
+	}
+	configuration.alarmResponders = alarmResponders
+	configuration.disabledParsers = parseDisabledParsers(configuration.DisabledParsers)
+
 	p.setConfiguration(configuration)
 
+	// the job is started on activation, only follow configuration changes afterwards
+	if p.BotUserID != "" {
+		p.ensureSESSummaryJob()
+	}
+
 	return nil
 }
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/mattermost/mattermost-plugin-aws-SNS/server/arn"
+)
+
+// regionCodes maps the region names CloudWatch sends in alarm notifications to region codes.
+var regionCodes = map[string]string{
+	"US East (N. Virginia)":     "us-east-1",
+	"US East (Ohio)":            "us-east-2",
+	"US West (N. California)":   "us-west-1",
+	"US West (Oregon)":          "us-west-2",
+	"Africa (Cape Town)":        "af-south-1",
+	"Asia Pacific (Hong Kong)":  "ap-east-1",
+	"Asia Pacific (Hyderabad)":  "ap-south-2",
+	"Asia Pacific (Jakarta)":    "ap-southeast-3",
+	"Asia Pacific (Melbourne)":  "ap-southeast-4",
+	"Asia Pacific (Mumbai)":     "ap-south-1",
+	"Asia Pacific (Osaka)":      "ap-northeast-3",
+	"Asia Pacific (Seoul)":      "ap-northeast-2",
+	"Asia Pacific (Singapore)":  "ap-southeast-1",
+	"Asia Pacific (Sydney)":     "ap-southeast-2",
+	"Asia Pacific (Tokyo)":      "ap-northeast-1",
+	"Canada (Central)":          "ca-central-1",
+	"Canada West (Calgary)":     "ca-west-1",
+	"China (Beijing)":           "cn-north-1",
+	"China (Ningxia)":           "cn-northwest-1",
+	"EU (Frankfurt)":            "eu-central-1",
+	"EU (Zurich)":               "eu-central-2",
+	"EU (Ireland)":              "eu-west-1",
+	"EU (London)":               "eu-west-2",
+	"EU (Paris)":                "eu-west-3",
+	"EU (Milan)":                "eu-south-1",
+	"EU (Spain)":                "eu-south-2",
+	"EU (Stockholm)":            "eu-north-1",
+	"Europe (Frankfurt)":        "eu-central-1",
+	"Europe (Zurich)":           "eu-central-2",
+	"Europe (Ireland)":          "eu-west-1",
+	"Europe (London)":           "eu-west-2",
+	"Europe (Paris)":            "eu-west-3",
+	"Europe (Milan)":            "eu-south-1",
+	"Europe (Spain)":            "eu-south-2",
+	"Europe (Stockholm)":        "eu-north-1",
+	"Israel (Tel Aviv)":         "il-central-1",
+	"Middle East (Bahrain)":     "me-south-1",
+	"Middle East (UAE)":         "me-central-1",
+	"South America (Sao Paulo)": "sa-east-1",
+	"AWS GovCloud (US-East)":    "us-gov-east-1",
+	"AWS GovCloud (US-West)":    "us-gov-west-1",
+}
+
+// arnConsoleURL links an ARN to the resource in the AWS console of its partition, or returns an
+// empty string when value is not an ARN.
+func arnConsoleURL(value string) string {
+	if !arn.IsARN(value) {
+		return ""
+	}
+	return fmt.Sprintf("%s/go/view?arn=%s", consoleBaseURL(arnPartition(value)), url.QueryEscape(value))
+}
+
+// arnPartition returns the partition of an ARN, defaulting to the aws partition.
+func arnPartition(value string) string {
+	parsed, err := arn.Parse(value)
+	if err != nil {
+		return "aws"
+	}
+	return parsed.Partition
+}
+
+// consoleBaseURL returns the address of the AWS console of a partition.
+func consoleBaseURL(partition string) string {
+	switch partition {
+	case "aws-cn":
+		return "https://console.amazonaws.cn"
+	case "aws-us-gov":
+		return "https://console.amazonaws-us-gov.com"
+	default:
+		return "https://console.aws.amazon.com"
+	}
+}
+
+// regionPartition returns the partition of a region code.
+func regionPartition(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return "aws-cn"
+	case strings.HasPrefix(region, "us-gov-"):
+		return "aws-us-gov"
+	default:
+		return "aws"
+	}
+}
+
+// alarmConsoleURL links to the details page of a CloudWatch alarm. The region comes from the
+// alarm ARN when CloudWatch sends it, and from the region name otherwise.
+func alarmConsoleURL(messageNotification SNSMessageNotification) string {
+	partition, region := "", ""
+	if alarmArn, err := arn.Parse(messageNotification.AlarmArn); err == nil {
+		partition, region = alarmArn.Partition, alarmArn.Region
+	}
+	if region == "" {
+		region = regionCodes[messageNotification.Region]
+		partition = regionPartition(region)
+	}
+	if region == "" || messageNotification.AlarmName == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/cloudwatch/home?region=%s#alarmsV2:alarm/%s",
+		consoleBaseURL(partition), region, url.PathEscape(messageNotification.AlarmName))
+}
+
+// stackEventsConsoleURL links to the events page of a CloudFormation stack.
+func stackEventsConsoleURL(stackID string) string {
+	stackArn, err := arn.Parse(stackID)
+	if err != nil || stackArn.Region == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/cloudformation/home?region=%s#/stacks/events?stackId=%s",
+		consoleBaseURL(stackArn.Partition), stackArn.Region, url.QueryEscape(stackID))
+}
+
+// rdsConsoleURL links to the RDS resource an event is about. Without a source ARN it falls back
+// to the identifier link sent by RDS.
+func rdsConsoleURL(messageNotification SNSRdsEventNotification) string {
+	sourceArn, err := arn.Parse(messageNotification.SourceARN)
+	if err != nil || sourceArn.Region == "" || messageNotification.SourceID == "" {
+		return messageNotification.IdentifierLink
+	}
+
+	var fragment string
+	switch messageNotification.EventSource {
+	case "db-instance":
+		fragment = "database:id=" + messageNotification.SourceID + ";is-cluster=false"
+	case "db-cluster":
+		fragment = "database:id=" + messageNotification.SourceID + ";is-cluster=true"
+	case "db-snapshot":
+		fragment = "db-snapshot:id=" + messageNotification.SourceID
+	case "db-cluster-snapshot":
+		fragment = "db-cluster-snapshot:id=" + messageNotification.SourceID
+	default:
+		return messageNotification.IdentifierLink
+	}
+	return fmt.Sprintf("%s/rds/home?region=%s#%s", consoleBaseURL(sourceArn.Partition), sourceArn.Region, fragment)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlarmConsoleURL(t *testing.T) {
+	for name, test := range map[string]struct {
+		Alarm       SNSMessageNotification
+		ExpectedURL string
+	}{
+		"Region name": {
+			Alarm:       SNSMessageNotification{AlarmName: "High CPU/web", Region: "EU (Ireland)"},
+			ExpectedURL: "https://console.aws.amazon.com/cloudwatch/home?region=eu-west-1#alarmsV2:alarm/High%20CPU%2Fweb",
+		},
+		"Alarm ARN": {
+			Alarm:       SNSMessageNotification{AlarmName: "cpu", AlarmArn: "arn:aws-cn:cloudwatch:cn-north-1:123456789012:alarm:cpu", Region: "China (Beijing)"},
+			ExpectedURL: "https://console.amazonaws.cn/cloudwatch/home?region=cn-north-1#alarmsV2:alarm/cpu",
+		},
+		"GovCloud region name": {
+			Alarm:       SNSMessageNotification{AlarmName: "cpu", Region: "AWS GovCloud (US-West)"},
+			ExpectedURL: "https://console.amazonaws-us-gov.com/cloudwatch/home?region=us-gov-west-1#alarmsV2:alarm/cpu",
+		},
+		"Unknown region": {
+			Alarm: SNSMessageNotification{AlarmName: "cpu", Region: "Moon (Tranquility Base)"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.ExpectedURL, alarmConsoleURL(test.Alarm))
+		})
+	}
+}
+
+func TestStackEventsConsoleURL(t *testing.T) {
+	assert.Equal(t,
+		"https://console.aws.amazon.com/cloudformation/home?region=us-east-1#/stacks/events?stackId=arn%3Aaws%3Acloudformation%3Aus-east-1%3A123456789012%3Astack%2Fweb%2Fabc",
+		stackEventsConsoleURL("arn:aws:cloudformation:us-east-1:123456789012:stack/web/abc"))
+	assert.Empty(t, stackEventsConsoleURL("web"))
+}
+
+func TestRDSConsoleURL(t *testing.T) {
+	for name, test := range map[string]struct {
+		Event       SNSRdsEventNotification
+		ExpectedURL string
+	}{
+		"Instance": {
+			Event:       SNSRdsEventNotification{EventSource: "db-instance", SourceID: "prod-db-1", SourceARN: "arn:aws:rds:us-east-2:123456789012:db:prod-db-1"},
+			ExpectedURL: "https://console.aws.amazon.com/rds/home?region=us-east-2#database:id=prod-db-1;is-cluster=false",
+		},
+		"Cluster": {
+			Event:       SNSRdsEventNotification{EventSource: "db-cluster", SourceID: "prod", SourceARN: "arn:aws-us-gov:rds:us-gov-east-1:123456789012:cluster:prod"},
+			ExpectedURL: "https://console.amazonaws-us-gov.com/rds/home?region=us-gov-east-1#database:id=prod;is-cluster=true",
+		},
+		"Identifier link without source ARN": {
+			Event:       SNSRdsEventNotification{EventSource: "db-instance", SourceID: "prod-db-1", IdentifierLink: "https://console.aws.amazon.com/rds/home?region=us-east-1#dbinstance:id=prod-db-1"},
+			ExpectedURL: "https://console.aws.amazon.com/rds/home?region=us-east-1#dbinstance:id=prod-db-1",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.ExpectedURL, rdsConsoleURL(test.Event))
+		})
+	}
+}
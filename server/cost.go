@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-plugin-aws-SNS/server/arn"
+)
+
+const (
+	budgetNotificationPrefix = "AWS Budget Notification"
+
+	// anomalies with a total impact of at least these amounts, in dollars, are colored orange and red
+	costAnomalyImpactMedium = 100
+	costAnomalyImpactHigh   = 1000
+)
+
+// budgetParser handles AWS Budgets alerts.
+type budgetParser struct {
+	p *Plugin
+}
+
+func (b *budgetParser) Name() string  { return "budgets" }
+func (b *budgetParser) Priority() int { return 430 }
+
+func (b *budgetParser) Detect(notification *SNSNotification) bool {
+	isBudgetNotification, _ := parseBudgetNotification(notification.Message)
+	return isBudgetNotification
+}
+
+func (b *budgetParser) Parse(notification *SNSNotification) (interface{}, error) {
+	isBudgetNotification, messageNotification := parseBudgetNotification(notification.Message)
+	if !isBudgetNotification {
+		return nil, errors.New("message is not an AWS Budgets alert")
+	}
+	return messageNotification, nil
+}
+
+func (b *budgetParser) Render(notification *SNSNotification, event interface{}) []*model.SlackAttachment {
+	attachment := b.p.createSNSBudgetAttachment(notification.Subject, notification.TopicArn, event.(SNSBudgetNotification))
+	return []*model.SlackAttachment{&attachment}
+}
+
+// parseBudgetNotification reads the "Name: value" lines of the plain text AWS Budgets alert.
+func parseBudgetNotification(message string) (bool, SNSBudgetNotification) {
+	var messageNotification SNSBudgetNotification
+	if !strings.HasPrefix(strings.TrimSpace(message), budgetNotificationPrefix) {
+		return false, messageNotification
+	}
+
+	for _, line := range strings.Split(message, "\n") {
+		line = strings.TrimSpace(line)
+		if accountID := strings.TrimPrefix(line, "AWS Account "); accountID != line {
+			messageNotification.AccountID = accountID
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		name, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		switch name {
+		case "Budget Name":
+			messageNotification.BudgetName = value
+		case "Budget Type":
+			messageNotification.BudgetType = value
+		case "Budgeted Amount":
+			messageNotification.BudgetedAmount = value
+		case "Alert Type":
+			messageNotification.AlertType = value
+		case "Alert Threshold":
+			messageNotification.AlertThreshold = value
+		case "ACTUAL Amount", "FORECASTED Amount":
+			messageNotification.Amount = value
+		}
+	}
+
+	return messageNotification.BudgetName != "", messageNotification
+}
+
+// createSNSBudgetAttachment renders a budget alert. Alerts carry no ARN, so the console of the
+// partition of the topic they were published to is linked.
+func (p *Plugin) createSNSBudgetAttachment(subject, topicArn string, messageNotification SNSBudgetNotification) model.SlackAttachment {
+	p.API.LogDebug("AWSSNS HandleNotification Budget Alert", "BUDGET", messageNotification.BudgetName)
+
+	var fields []*model.SlackAttachmentField
+
+	fields = addFields(fields, "Budget Name", messageNotification.BudgetName, true)
+	fields = addFields(fields, "Budget Type", messageNotification.BudgetType, true)
+	fields = addFields(fields, "Budgeted Amount", messageNotification.BudgetedAmount, true)
+	fields = addFields(fields, "Alert Threshold", messageNotification.AlertThreshold, true)
+	if messageNotification.AlertType == "FORECASTED" {
+		fields = addFields(fields, "Forecasted Spend", messageNotification.Amount, true)
+	} else {
+		fields = addFields(fields, "Actual Spend", messageNotification.Amount, true)
+	}
+	fields = addFields(fields, "AWS Account", messageNotification.AccountID, true)
+
+	title := subject
+	if title == "" {
+		title = fmt.Sprintf("AWS Budgets: %s", messageNotification.BudgetName)
+	}
+
+	color := colorRed
+	if messageNotification.AlertType == "FORECASTED" {
+		color = colorOrange
+	}
+
+	return model.SlackAttachment{
+		Title:     title,
+		TitleLink: consoleBaseURL(arnPartition(topicArn)) + "/billing/home#/budgets",
+		Fields:    fields,
+		Color:     color,
+	}
+}
+
+// costAnomalyParser handles AWS Cost Anomaly Detection alerts.
+type costAnomalyParser struct {
+	p *Plugin
+}
+
+func (c *costAnomalyParser) Name() string  { return "costanomaly" }
+func (c *costAnomalyParser) Priority() int { return 440 }
+
+func (c *costAnomalyParser) Detect(notification *SNSNotification) bool {
+	isCostAnomaly, _ := c.p.isCostAnomaly(notification.Message)
+	return isCostAnomaly
+}
+
+func (c *costAnomalyParser) Parse(notification *SNSNotification) (interface{}, error) {
+	isCostAnomaly, messageNotification := c.p.isCostAnomaly(notification.Message)
+	if !isCostAnomaly {
+		return nil, errors.New("message is not a Cost Anomaly Detection alert")
+	}
+	return messageNotification, nil
+}
+
+func (c *costAnomalyParser) Render(notification *SNSNotification, event interface{}) []*model.SlackAttachment {
+	attachment := c.p.createSNSCostAnomalyAttachment(event.(SNSCostAnomalyNotification))
+	return []*model.SlackAttachment{&attachment}
+}
+
+func (p *Plugin) isCostAnomaly(message string) (bool, SNSCostAnomalyNotification) {
+	var messageNotification SNSCostAnomalyNotification
+	if err := json.Unmarshal([]byte(message), &messageNotification); err != nil {
+		p.API.LogDebug(
+			"AWSSNS HandleNotification Decode Error on Cost Anomaly message notification",
+			"err", err.Error(),
+			"message", message)
+		return false, messageNotification
+	}
+	return len(messageNotification.AnomalyID) > 0, messageNotification
+}
+
+func (p *Plugin) createSNSCostAnomalyAttachment(messageNotification SNSCostAnomalyNotification) model.SlackAttachment {
+	p.API.LogDebug("AWSSNS HandleNotification Cost Anomaly", "ANOMALY", messageNotification.AnomalyID)
+
+	impact := messageNotification.Impact
+	var fields []*model.SlackAttachmentField
+
+	fields = addFields(fields, "Impact", fmt.Sprintf("$%.2f (%.2f%%)", impact.TotalImpact, impact.TotalImpactPercentage), true)
+	fields = addFields(fields, "Actual Spend", fmt.Sprintf("$%.2f", impact.TotalActualSpend), true)
+	fields = addFields(fields, "Expected Spend", fmt.Sprintf("$%.2f", impact.TotalExpectedSpend), true)
+	fields = addFields(fields, "Monitor", messageNotification.MonitorName, true)
+	fields = addFields(fields, "Start Date", messageNotification.AnomalyStartDate, true)
+	fields = addFields(fields, "AWS Account", messageNotification.AccountID, true)
+
+	var rootCauses []string
+	for _, rootCause := range messageNotification.RootCauses {
+		var parts []string
+		for _, part := range []string{rootCause.Service, rootCause.LinkedAccount, rootCause.Region, rootCause.UsageType} {
+			if part != "" {
+				parts = append(parts, part)
+			}
+		}
+		rootCauses = append(rootCauses, strings.Join(parts, ", "))
+	}
+	fields = addFields(fields, "Root Causes", strings.Join(rootCauses, "\n"), false)
+
+	return model.SlackAttachment{
+		Title:     fmt.Sprintf("AWS Cost Anomaly: $%.2f above the expected spend", impact.TotalImpact),
+		TitleLink: costAnomalyURL(messageNotification),
+		Fields:    fields,
+		Color:     costAnomalyColor(impact.TotalImpact),
+		Footer:    messageNotification.AnomalyID,
+	}
+}
+
+// costAnomalyURL links to the anomaly in the Cost Management console, or returns an empty
+// string when the alert has neither a details link nor a monitor ARN.
+func costAnomalyURL(messageNotification SNSCostAnomalyNotification) string {
+	if messageNotification.AnomalyDetailsLink != "" {
+		return messageNotification.AnomalyDetailsLink
+	}
+
+	monitorArn, err := arn.Parse(messageNotification.MonitorArn)
+	if err != nil || monitorArn.ResourceName() == "" || messageNotification.AnomalyID == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("%s/cost-management/home#/anomaly-detection/monitors/%s/anomalies/%s",
+		consoleBaseURL(monitorArn.Partition), monitorArn.ResourceName(), messageNotification.AnomalyID)
+}
+
+// costAnomalyColor scales the color of an anomaly with its impact in dollars.
+func costAnomalyColor(impact float64) string {
+	switch {
+	case impact >= costAnomalyImpactHigh:
+		return colorRed
+	case impact >= costAnomalyImpactMedium:
+		return colorOrange
+	default:
+		return colorYellow
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	budgetMessage = `AWS Budget Notification May 28, 2023
+AWS Account 123456789012
+
+Dear AWS Customer,
+
+You requested that we alert you when the FORECASTED Cost associated with your Monthly budget is greater than $80.00 for the current month. The FORECASTED Cost associated with this budget is $120.00. You can find additional details below and by accessing the AWS Budgets dashboard [1].
+
+Budget Name: Monthly budget
+Budget Type: Cost
+Budgeted Amount: $100.00
+Alert Type: FORECASTED
+Alert Threshold: > $80.00
+FORECASTED Amount: $120.00
+
+[1] https://console.aws.amazon.com/billing/home#/budgets
+`
+	costAnomalyMessage = `{
+		"accountId": "123456789012",
+		"anomalyId": "12345678-abcd-ef12-3456-987654321a12",
+		"anomalyScore": {"currentScore": 0.47, "maxScore": 0.47},
+		"anomalyStartDate": "2021-05-25T00:00:00Z",
+		"anomalyEndDate": "2021-05-25T00:00:00Z",
+		"dimensionalValue": "ServiceName",
+		"impact": {"maxImpact": 151, "totalActualSpend": 1301, "totalExpectedSpend": 1150, "totalImpact": 151, "totalImpactPercentage": 13.13},
+		"monitorArn": "arn:aws:ce::123456789012:anomalymonitor/12345678-abcd-ef12-3456-987654321a12",
+		"monitorName": "services",
+		"rootCauses": [{"linkedAccount": "123456789012", "region": "us-east-1", "service": "Amazon Elastic Compute Cloud - Compute", "usageType": "BoxUsage:c5.4xlarge"}]
+	}`
+)
+
+func TestBudgetParser(t *testing.T) {
+	api := &plugintest.API{}
+	api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything).Maybe()
+	p := &Plugin{}
+	p.SetAPI(api)
+	parser := &budgetParser{p: p}
+
+	notification := &SNSNotification{Subject: "AWS Budgets: Monthly budget has exceeded your alert threshold", Message: budgetMessage}
+	require.True(t, parser.Detect(notification))
+	assert.False(t, parser.Detect(&SNSNotification{Message: "hello"}))
+
+	event, err := parser.Parse(notification)
+	require.NoError(t, err)
+	attachments := parser.Render(notification, event)
+	require.Len(t, attachments, 1)
+
+	assert.Equal(t, "AWS Budgets: Monthly budget has exceeded your alert threshold", attachments[0].Title)
+	assert.Equal(t, colorOrange, attachments[0].Color)
+	values := renderFields(attachments[0])
+	assert.Equal(t, "Monthly budget", values["Budget Name"])
+	assert.Equal(t, "$100.00", values["Budgeted Amount"])
+	assert.Equal(t, "> $80.00", values["Alert Threshold"])
+	assert.Equal(t, "$120.00", values["Forecasted Spend"])
+	assert.Equal(t, "123456789012", values["AWS Account"])
+}
+
+func TestCostAnomalyParser(t *testing.T) {
+	api := &plugintest.API{}
+	api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything).Maybe()
+	p := &Plugin{}
+	p.SetAPI(api)
+	parser := &costAnomalyParser{p: p}
+
+	notification := &SNSNotification{Message: costAnomalyMessage}
+	require.True(t, parser.Detect(notification))
+
+	event, err := parser.Parse(notification)
+	require.NoError(t, err)
+	attachments := parser.Render(notification, event)
+	require.Len(t, attachments, 1)
+
+	assert.Equal(t, "AWS Cost Anomaly: $151.00 above the expected spend", attachments[0].Title)
+	assert.Equal(t, "https://console.aws.amazon.com/cost-management/home#/anomaly-detection/monitors/12345678-abcd-ef12-3456-987654321a12/anomalies/12345678-abcd-ef12-3456-987654321a12", attachments[0].TitleLink)
+	assert.Equal(t, colorOrange, attachments[0].Color)
+	values := renderFields(attachments[0])
+	assert.Equal(t, "$151.00 (13.13%)", values["Impact"])
+	assert.Equal(t, "$1301.00", values["Actual Spend"])
+	assert.Equal(t, "Amazon Elastic Compute Cloud - Compute, 123456789012, us-east-1, BoxUsage:c5.4xlarge", values["Root Causes"])
+}
+
+func TestCostAnomalyColor(t *testing.T) {
+	assert.Equal(t, colorYellow, costAnomalyColor(20))
+	assert.Equal(t, colorOrange, costAnomalyColor(100))
+	assert.Equal(t, colorRed, costAnomalyColor(2500))
+}
+
+func TestCostConsoleLinks(t *testing.T) {
+	p := &Plugin{}
+	api := &plugintest.API{}
+	api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything).Maybe()
+	p.SetAPI(api)
+
+	attachment := p.createSNSBudgetAttachment("", "arn:aws-cn:sns:cn-north-1:123456789012:budgets", SNSBudgetNotification{BudgetName: "monthly"})
+	assert.Equal(t, "https://console.amazonaws.cn/billing/home#/budgets", attachment.TitleLink)
+
+	assert.Equal(t,
+		"https://console.amazonaws-us-gov.com/cost-management/home#/anomaly-detection/monitors/monitorId/anomalies/anomalyId",
+		costAnomalyURL(SNSCostAnomalyNotification{MonitorArn: "arn:aws-us-gov:ce::123456789012:anomalymonitor/monitorId", AnomalyID: "anomalyId"}),
+	)
+	assert.Empty(t, costAnomalyURL(SNSCostAnomalyNotification{AnomalyID: "anomalyId"}))
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"github.com/mattermost/mattermost-plugin-aws-SNS/server/arn"
+	"github.com/pkg/errors"
+)
+
+const (
+	ecsTaskDetailType    = "ECS Task State Change"
+	ecsServiceDetailType = "ECS Service Action"
+)
+
+// ecsEvent is an ECS task state change or service action along with the EventBridge event that
+// carried it. Exactly one of Task and Service is set.
+type ecsEvent struct {
+	Event   *EventBridgeEvent
+	Task    *ECSTaskStateChange
+	Service *ECSServiceAction
+}
+
+// ecsParser handles ECS task state changes and service actions.
+type ecsParser struct {
+	p *Plugin
+}
+
+func (e *ecsParser) Name() string  { return "ecs" }
+func (e *ecsParser) Priority() int { return 940 }
+
+func (e *ecsParser) Detect(notification *SNSNotification) bool {
+	isECSEvent, _ := e.p.isECSEvent(notification.Message)
+	return isECSEvent
+}
+
+func (e *ecsParser) Parse(notification *SNSNotification) (interface{}, error) {
+	isECSEvent, event := e.p.isECSEvent(notification.Message)
+	if !isECSEvent {
+		return nil, errors.New("message is not an ECS event")
+	}
+	return event, nil
+}
+
+func (e *ecsParser) Render(notification *SNSNotification, event interface{}) []*model.SlackAttachment {
+	ecs := event.(*ecsEvent)
+	if ecs.Task != nil {
+		return []*model.SlackAttachment{e.p.createECSTaskAttachment(ecs.Event, *ecs.Task)}
+	}
+	return []*model.SlackAttachment{e.p.createECSServiceAttachment(ecs.Event, *ecs.Service)}
+}
+
+func (p *Plugin) isECSEvent(message string) (bool, *ecsEvent) {
+	event, ok := isEventBridgeEvent(message, "aws.ecs", ecsTaskDetailType, ecsServiceDetailType)
+	if !ok {
+		return false, nil
+	}
+
+	if event.DetailType == ecsTaskDetailType {
+		var task ECSTaskStateChange
+		if err := json.Unmarshal(event.Detail, &task); err != nil {
+			p.API.LogDebug(
+				"AWSSNS HandleNotification Decode Error on ECS task state change",
+				"err", err.Error(),
+				"message", message)
+			return false, nil
+		}
+		return len(task.TaskArn) > 0, &ecsEvent{Event: event, Task: &task}
+	}
+
+	var service ECSServiceAction
+	if err := json.Unmarshal(event.Detail, &service); err != nil {
+		p.API.LogDebug(
+			"AWSSNS HandleNotification Decode Error on ECS service action",
+			"err", err.Error(),
+			"message", message)
+		return false, nil
+	}
+	return len(service.EventName) > 0, &ecsEvent{Event: event, Service: &service}
+}
+
+func (p *Plugin) createECSTaskAttachment(event *EventBridgeEvent, task ECSTaskStateChange) *model.SlackAttachment {
+	p.API.LogDebug("AWSSNS HandleNotification ECS Task", "TASK", task.TaskArn, "STATUS", task.LastStatus)
+
+	var fields []*model.SlackAttachmentField
+
+	fields = addFields(fields, "Cluster", arnResourceName(task.ClusterArn), true)
+	if strings.HasPrefix(task.Group, "service:") {
+		fields = addFields(fields, "Service", strings.TrimPrefix(task.Group, "service:"), true)
+	} else if task.Group != "" {
+		fields = addFields(fields, "Group", task.Group, true)
+	}
+	fields = addFields(fields, "Task Definition", arnResourceName(task.TaskDefinitionArn), true)
+	fields = addFields(fields, "Last Status", task.LastStatus, true)
+	fields = addFields(fields, "Desired Status", task.DesiredStatus, true)
+	if task.StopCode != "" {
+		fields = addFields(fields, "Stop Code", task.StopCode, true)
+	}
+	if task.StoppedReason != "" {
+		fields = addFields(fields, "Stopped Reason", task.StoppedReason, false)
+	}
+
+	var containers []string
+	for _, container := range task.Containers {
+		if container.ExitCode == nil {
+			continue
+		}
+		line := fmt.Sprintf("%s: exit code %d", container.Name, *container.ExitCode)
+		if container.Reason != "" {
+			line += " (" + container.Reason + ")"
+		}
+		containers = append(containers, line)
+	}
+	if len(containers) > 0 {
+		fields = addFields(fields, "Containers", strings.Join(containers, "\n"), false)
+	}
+
+	fields = addFields(fields, "AWS Account", event.Account, true)
+	fields = addFields(fields, "Region", event.Region, true)
+
+	attachment := &model.SlackAttachment{
+		Title:     fmt.Sprintf("ECS task %s", task.LastStatus),
+		TitleLink: arnConsoleURL(task.TaskArn),
+		Fields:    fields,
+		Footer:    task.TaskArn,
+	}
+	switch {
+	case ecsTaskStoppedUnexpectedly(task):
+		attachment.Color = colorRed
+	case task.LastStatus == "RUNNING":
+		attachment.Color = colorGreen
+	}
+
+	return attachment
+}
+
+func (p *Plugin) createECSServiceAttachment(event *EventBridgeEvent, service ECSServiceAction) *model.SlackAttachment {
+	p.API.LogDebug("AWSSNS HandleNotification ECS Service Action", "EVENT", service.EventName)
+
+	var fields []*model.SlackAttachmentField
+
+	fields = addFields(fields, "Cluster", arnResourceName(service.ClusterArn), true)
+	for _, resource := range event.Resources {
+		fields = addFields(fields, "Service", arnResourceName(resource), true)
+	}
+	fields = addFields(fields, "Event Type", service.EventType, true)
+	fields = addFields(fields, "Created At", service.CreatedAt, true)
+	if service.Reason != "" {
+		fields = addFields(fields, "Reason", service.Reason, false)
+	}
+	fields = addFields(fields, "AWS Account", event.Account, true)
+	fields = addFields(fields, "Region", event.Region, true)
+
+	attachment := &model.SlackAttachment{
+		Title:  fmt.Sprintf("ECS service %s", service.EventName),
+		Fields: fields,
+	}
+	switch service.EventType {
+	case "ERROR":
+		attachment.Color = colorRed
+	case "WARN":
+		attachment.Color = colorOrange
+	}
+
+	return attachment
+}
+
+// ecsTaskStoppedUnexpectedly reports whether a stopped task failed, rather than being stopped by
+// a user or by the service scheduler, e.g. during a deployment.
+func ecsTaskStoppedUnexpectedly(task ECSTaskStateChange) bool {
+	if task.LastStatus != "STOPPED" {
+		return false
+	}
+	if task.StopCode == "EssentialContainerExited" || task.StopCode == "TaskFailedToStart" {
+		return true
+	}
+	for _, container := range task.Containers {
+		if container.ExitCode != nil && *container.ExitCode != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// arnResourceName returns the last part of the resource of an ARN, e.g. the cluster name of
+// arn:aws:ecs:us-east-1:123456789012:cluster/default. Anything else is returned as is.
+func arnResourceName(value string) string {
+	parsed, err := arn.Parse(value)
+	if err != nil {
+		return value
+	}
+	return parsed.ResourceName()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ecsTaskMessage(stopCode string, exitCode int) string {
+	return `{
+		"version": "0",
+		"id": "3317b2af-7005-947d-b652-f55e762e571a",
+		"detail-type": "ECS Task State Change",
+		"source": "aws.ecs",
+		"account": "111122223333",
+		"time": "2020-01-23T17:57:58Z",
+		"region": "us-west-2",
+		"resources": ["arn:aws:ecs:us-west-2:111122223333:task/FargateCluster/c13b4cb40f1f4fe4a2971f76ae5a47ad"],
+		"detail": {
+			"clusterArn": "arn:aws:ecs:us-west-2:111122223333:cluster/FargateCluster",
+			"taskArn": "arn:aws:ecs:us-west-2:111122223333:task/FargateCluster/c13b4cb40f1f4fe4a2971f76ae5a47ad",
+			"taskDefinitionArn": "arn:aws:ecs:us-west-2:111122223333:task-definition/web:3",
+			"group": "service:web",
+			"launchType": "FARGATE",
+			"lastStatus": "STOPPED",
+			"desiredStatus": "STOPPED",
+			"stopCode": "` + stopCode + `",
+			"stoppedReason": "Essential container in task exited",
+			"containers": [
+				{"name": "web", "lastStatus": "STOPPED", "exitCode": ` + strconv.Itoa(exitCode) + `},
+				{"name": "sidecar", "lastStatus": "STOPPED"}
+			]
+		}
+	}`
+}
+
+func TestECSParser(t *testing.T) {
+	api := &plugintest.API{}
+	api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+	p := &Plugin{}
+	p.SetAPI(api)
+	parser := &ecsParser{p: p}
+
+	for name, test := range map[string]struct {
+		Message       string
+		ExpectedTitle string
+		ExpectedColor string
+		ExpectedField map[string]string
+	}{
+		"Task stopped unexpectedly": {
+			Message:       ecsTaskMessage("EssentialContainerExited", 1),
+			ExpectedTitle: "ECS task STOPPED",
+			ExpectedColor: colorRed,
+			ExpectedField: map[string]string{
+				"Cluster":         "FargateCluster",
+				"Service":         "web",
+				"Task Definition": "web:3",
+				"Last Status":     "STOPPED",
+				"Desired Status":  "STOPPED",
+				"Stopped Reason":  "Essential container in task exited",
+				"Containers":      "web: exit code 1",
+			},
+		},
+		"Task stopped by the scheduler": {
+			Message:       ecsTaskMessage("ServiceSchedulerInitiated", 0),
+			ExpectedTitle: "ECS task STOPPED",
+			ExpectedField: map[string]string{
+				"Stop Code":  "ServiceSchedulerInitiated",
+				"Containers": "web: exit code 0",
+			},
+		},
+		"Service action": {
+			Message: `{
+				"version": "0",
+				"id": "af3c496d-f4a8-65d1-70f4-a69d52e9b584",
+				"detail-type": "ECS Service Action",
+				"source": "aws.ecs",
+				"account": "111122223333",
+				"time": "2019-11-19T19:27:22Z",
+				"region": "us-west-2",
+				"resources": ["arn:aws:ecs:us-west-2:111122223333:service/default/servicetest"],
+				"detail": {"eventType": "WARN", "eventName": "SERVICE_TASK_PLACEMENT_FAILURE", "clusterArn": "arn:aws:ecs:us-west-2:111122223333:cluster/default", "createdAt": "2019-11-19T19:27:22.695Z", "reason": "RESOURCE:CPU"}
+			}`,
+			ExpectedTitle: "ECS service SERVICE_TASK_PLACEMENT_FAILURE",
+			ExpectedColor: colorOrange,
+			ExpectedField: map[string]string{
+				"Cluster": "default",
+				"Service": "default/servicetest",
+				"Reason":  "RESOURCE:CPU",
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			notification := &SNSNotification{Message: test.Message}
+			require.True(t, parser.Detect(notification))
+
+			event, err := parser.Parse(notification)
+			require.NoError(t, err)
+			attachments := parser.Render(notification, event)
+			require.Len(t, attachments, 1)
+
+			assert.Equal(t, test.ExpectedTitle, attachments[0].Title)
+			assert.Equal(t, test.ExpectedColor, attachments[0].Color)
+			values := renderFields(attachments[0])
+			for title, value := range test.ExpectedField {
+				assert.Equal(t, value, values[title], title)
+			}
+		})
+	}
+}
+
+func TestArnResourceName(t *testing.T) {
+	assert.Equal(t, "default", arnResourceName("arn:aws:ecs:us-east-1:123456789012:cluster/default"))
+	assert.Equal(t, "my-bucket", arnResourceName("arn:aws:s3:::my-bucket"))
+	assert.Equal(t, "web", arnResourceName("web"))
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxDetailFields caps the number of fields a flattened EventBridge detail is rendered into.
+	maxDetailFields = 25
+	// maxDetailValueLength caps the number of characters of a single flattened detail value.
+	maxDetailValueLength = 500
+	// shortFieldLength is the longest value still rendered as a short field.
+	shortFieldLength = 40
+)
+
+// eventBridgeParser handles events delivered by EventBridge rules that have no dedicated parser.
+// Parsers for specific sources use a lower priority, so they get the events of their source first.
+type eventBridgeParser struct {
+	p *Plugin
+}
+
+func (e *eventBridgeParser) Name() string  { return "eventbridge" }
+func (e *eventBridgeParser) Priority() int { return 1000 }
+
+func (e *eventBridgeParser) Detect(notification *SNSNotification) bool {
+	_, ok := parseEventBridgeEvent(notification.Message)
+	return ok
+}
+
+func (e *eventBridgeParser) Parse(notification *SNSNotification) (interface{}, error) {
+	event, ok := parseEventBridgeEvent(notification.Message)
+	if !ok {
+		return nil, errors.New("message is not an EventBridge event")
+	}
+	return event, nil
+}
+
+func (e *eventBridgeParser) Render(notification *SNSNotification, event interface{}) []*model.SlackAttachment {
+	attachment := e.p.createEventBridgeAttachment(event.(*EventBridgeEvent))
+	return []*model.SlackAttachment{attachment}
+}
+
+// parseEventBridgeEvent decodes the EventBridge envelope, as well as the variant AWS CodeStar
+// Notifications send. Messages without a source, detail type and detail object are not
+// EventBridge events.
+func parseEventBridgeEvent(message string) (*EventBridgeEvent, bool) {
+	var event EventBridgeEvent
+	if err := json.Unmarshal([]byte(message), &event); err != nil {
+		return nil, false
+	}
+	if event.DetailType == "" {
+		event.DetailType = event.NotificationDetailType
+	}
+	if event.Source == "" || event.DetailType == "" || len(event.Detail) == 0 {
+		return nil, false
+	}
+	return &event, true
+}
+
+// isEventBridgeEvent reports whether the message is an EventBridge event of the given source and,
+// when detailTypes are set, of one of these detail types.
+func isEventBridgeEvent(message, source string, detailTypes ...string) (*EventBridgeEvent, bool) {
+	event, ok := parseEventBridgeEvent(message)
+	if !ok || event.Source != source {
+		return nil, false
+	}
+	if len(detailTypes) == 0 {
+		return event, true
+	}
+	for _, detailType := range detailTypes {
+		if event.DetailType == detailType {
+			return event, true
+		}
+	}
+	return nil, false
+}
+
+func (p *Plugin) createEventBridgeAttachment(event *EventBridgeEvent) *model.SlackAttachment {
+	p.API.LogDebug("AWSSNS HandleNotification EventBridge Event", "SOURCE", event.Source, "DETAIL_TYPE", event.DetailType)
+
+	fields := eventBridgeEnvelopeFields(event)
+
+	var detail interface{}
+	if err := json.Unmarshal(event.Detail, &detail); err == nil {
+		fields = append(fields, flattenDetail(detail)...)
+	}
+
+	return &model.SlackAttachment{
+		Title:  fmt.Sprintf("%s (%s)", event.DetailType, event.Source),
+		Fields: fields,
+		Footer: event.ID,
+	}
+}
+
+// eventBridgeEnvelopeFields renders the account, region, time and resources of an event.
+func eventBridgeEnvelopeFields(event *EventBridgeEvent) []*model.SlackAttachmentField {
+	var fields []*model.SlackAttachmentField
+	fields = addFields(fields, "AWS Account", event.Account, true)
+	fields = addFields(fields, "Region", event.Region, true)
+	fields = addFields(fields, "Time", event.Time, true)
+	if len(event.Resources) > 0 {
+		var resources []string
+		for _, resource := range event.Resources {
+			resources = append(resources, resourceLink(resource))
+		}
+		fields = addFields(fields, "Resources", strings.Join(resources, "\n"), false)
+	}
+	return fields
+}
+
+// flattenDetail turns a decoded detail object into one field per leaf value, titled with the
+// dotted path of the value, e.g. "instance.tags[0].key".
+func flattenDetail(detail interface{}) []*model.SlackAttachmentField {
+	var fields []*model.SlackAttachmentField
+	var truncated bool
+
+	var walk func(path string, value interface{})
+	walk = func(path string, value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				if path == "" {
+					walk(key, v[key])
+				} else {
+					walk(path+"."+key, v[key])
+				}
+			}
+		case []interface{}:
+			for i, item := range v {
+				walk(fmt.Sprintf("%s[%d]", path, i), item)
+			}
+		case nil:
+		default:
+			if len(fields) >= maxDetailFields {
+				truncated = true
+				return
+			}
+			text := detailValueString(v)
+			if utf8.RuneCountInString(text) > maxDetailValueLength {
+				text = string([]rune(text)[:maxDetailValueLength]) + "…"
+			}
+			fields = addFields(fields, path, text, len(text) <= shortFieldLength)
+		}
+	}
+	walk("", detail)
+
+	if truncated {
+		fields = addFields(fields, "", fmt.Sprintf("_Only the first %d values of the event detail are shown._", maxDetailFields), false)
+	}
+	return fields
+}
+
+func detailValueString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// resourceLink renders an ARN as a link to the resource in the AWS console of its partition.
+// Anything that is not an ARN is returned as is.
+func resourceLink(resource string) string {
+	link := arnConsoleURL(resource)
+	if link == "" {
+		return resource
+	}
+	return fmt.Sprintf("[%s](%s)", resource, link)
+}
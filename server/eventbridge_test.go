@@ -0,0 +1,109 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEventBridgeEvent(t *testing.T) {
+	for name, test := range map[string]struct {
+		Message  string
+		Expected bool
+	}{
+		"EventBridge event": {
+			Message:  `{"version": "0", "id": "1", "detail-type": "Scheduled Event", "source": "aws.events", "detail": {}}`,
+			Expected: true,
+		},
+		"Missing detail": {
+			Message: `{"version": "0", "id": "1", "detail-type": "Scheduled Event", "source": "aws.events"}`,
+		},
+		"CloudWatch alarm": {
+			Message: `{"AlarmName": "cpu", "NewStateValue": "ALARM"}`,
+		},
+		"Plain text": {
+			Message: "hello",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, ok := parseEventBridgeEvent(test.Message)
+			assert.Equal(t, test.Expected, ok)
+		})
+	}
+}
+
+func TestCreateEventBridgeAttachment(t *testing.T) {
+	api := &plugintest.API{}
+	api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+	p := &Plugin{}
+	p.SetAPI(api)
+
+	event, ok := parseEventBridgeEvent(`{
+		"version": "0",
+		"id": "6a7e8feb-b491-4cf7-a9f1-bf3703467718",
+		"detail-type": "EC2 Instance State-change Notification",
+		"source": "aws.ec2",
+		"account": "111122223333",
+		"time": "2017-12-22T18:43:48Z",
+		"region": "cn-north-1",
+		"resources": ["arn:aws-cn:ec2:cn-north-1:111122223333:instance/i-1234567890abcdef0"],
+		"detail": {"instance-id": "i-1234567890abcdef0", "state": "terminated", "tags": [{"key": "env", "value": "prod"}], "spot": false, "ignored": null}
+	}`)
+	require.True(t, ok)
+
+	attachment := p.createEventBridgeAttachment(event)
+	assert.Equal(t, "EC2 Instance State-change Notification (aws.ec2)", attachment.Title)
+
+	values := map[string]string{}
+	for _, field := range attachment.Fields {
+		values[field.Title] = field.Value.(string)
+	}
+	assert.Equal(t, map[string]string{
+		"AWS Account":   "111122223333",
+		"Region":        "cn-north-1",
+		"Time":          "2017-12-22T18:43:48Z",
+		"Resources":     "[arn:aws-cn:ec2:cn-north-1:111122223333:instance/i-1234567890abcdef0](https://console.amazonaws.cn/go/view?arn=arn%3Aaws-cn%3Aec2%3Acn-north-1%3A111122223333%3Ainstance%2Fi-1234567890abcdef0)",
+		"instance-id":   "i-1234567890abcdef0",
+		"spot":          "false",
+		"state":         "terminated",
+		"tags[0].key":   "env",
+		"tags[0].value": "prod",
+	}, values)
+}
+
+func TestFlattenDetailLimit(t *testing.T) {
+	detail := map[string]interface{}{}
+	for i := 0; i < maxDetailFields+5; i++ {
+		detail[string(rune('a'+i))] = float64(i)
+	}
+
+	fields := flattenDetail(detail)
+	require.Len(t, fields, maxDetailFields+1)
+	assert.Equal(t, "a", fields[0].Title)
+	assert.Equal(t, "0", fields[0].Value)
+	assert.Equal(t, model.SlackCompatibleBool(false), fields[maxDetailFields].Short)
+}
+
+func TestFlattenDetailValueLength(t *testing.T) {
+	fields := flattenDetail(map[string]interface{}{
+		"message": "a" + strings.Repeat("é", maxDetailValueLength),
+	})
+	require.Len(t, fields, 1)
+	assert.True(t, utf8.ValidString(fields[0].Value.(string)))
+	assert.Equal(t, "a"+strings.Repeat("é", maxDetailValueLength-1)+"…", fields[0].Value)
+}
+
+func TestResourceLink(t *testing.T) {
+	assert.Equal(t, "i-1234567890abcdef0", resourceLink("i-1234567890abcdef0"))
+	assert.Equal(t,
+		"[arn:aws:s3:::my-bucket](https://console.aws.amazon.com/go/view?arn=arn%3Aaws%3As3%3A%3A%3Amy-bucket)",
+		resourceLink("arn:aws:s3:::my-bucket"),
+	)
+	assert.Contains(t, resourceLink("arn:aws-us-gov:s3:::my-bucket"), "https://console.amazonaws-us-gov.com/")
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+// guardDutyParser handles GuardDuty findings, which GuardDuty publishes through EventBridge.
+type guardDutyParser struct {
+	p *Plugin
+}
+
+// guardDutyEvent is a GuardDuty finding along with the EventBridge event that carried it.
+type guardDutyEvent struct {
+	Event   *EventBridgeEvent
+	Finding GuardDutyFinding
+}
+
+func (g *guardDutyParser) Name() string  { return "guardduty" }
+func (g *guardDutyParser) Priority() int { return 900 }
+
+func (g *guardDutyParser) Detect(notification *SNSNotification) bool {
+	_, ok := isEventBridgeEvent(notification.Message, "aws.guardduty", "GuardDuty Finding")
+	return ok
+}
+
+func (g *guardDutyParser) Parse(notification *SNSNotification) (interface{}, error) {
+	event, ok := isEventBridgeEvent(notification.Message, "aws.guardduty", "GuardDuty Finding")
+	if !ok {
+		return nil, errors.New("message is not a GuardDuty finding")
+	}
+
+	var finding GuardDutyFinding
+	if err := json.Unmarshal(event.Detail, &finding); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal GuardDuty finding")
+	}
+	if finding.ID == "" || finding.Type == "" {
+		return nil, errors.New("GuardDuty finding has no id or type")
+	}
+
+	return &guardDutyEvent{Event: event, Finding: finding}, nil
+}
+
+func (g *guardDutyParser) Render(notification *SNSNotification, event interface{}) []*model.SlackAttachment {
+	return []*model.SlackAttachment{g.p.createGuardDutyFindingAttachment(event.(*guardDutyEvent))}
+}
+
+func (p *Plugin) createGuardDutyFindingAttachment(event *guardDutyEvent) *model.SlackAttachment {
+	finding := event.Finding
+	p.API.LogDebug("AWSSNS HandleNotification GuardDuty Finding", "TYPE", finding.Type)
+
+	accountID := finding.AccountID
+	if accountID == "" {
+		accountID = event.Event.Account
+	}
+	region := finding.Region
+	if region == "" {
+		region = event.Event.Region
+	}
+
+	label, color := guardDutySeverity(finding.Severity)
+
+	var fields []*model.SlackAttachmentField
+	fields = addFields(fields, "Finding Type", finding.Type, false)
+	fields = addFields(fields, "Severity", fmt.Sprintf("%s (%s)", label, strconv.FormatFloat(finding.Severity, 'f', -1, 64)), true)
+	fields = addFields(fields, "Count", strconv.Itoa(finding.Service.Count), true)
+	fields = addFields(fields, "AWS Account", accountID, true)
+	fields = addFields(fields, "Region", region, true)
+	fields = addFields(fields, "Resource", guardDutyResource(finding), false)
+	fields = addFields(fields, "Description", finding.Description, false)
+
+	title := finding.Title
+	if title == "" {
+		title = finding.Type
+	}
+
+	return &model.SlackAttachment{
+		Title:     title,
+		TitleLink: guardDutyFindingURL(finding.Partition, region, finding.ID),
+		Fields:    fields,
+		Color:     color,
+		Footer:    finding.Arn,
+	}
+}
+
+// guardDutySeverity maps a finding severity to its GuardDuty band and the matching color.
+func guardDutySeverity(severity float64) (string, string) {
+	switch {
+	case severity >= 7:
+		return "High", colorRed
+	case severity >= 4:
+		return "Medium", colorOrange
+	default:
+		return "Low", colorBlue
+	}
+}
+
+// guardDutyResource describes the resource a finding is about.
+func guardDutyResource(finding GuardDutyFinding) string {
+	resource := finding.Resource
+	switch {
+	case resource.InstanceDetails != nil && resource.InstanceDetails.InstanceID != "":
+		return "Instance " + resource.InstanceDetails.InstanceID
+	case resource.AccessKeyDetails != nil && resource.AccessKeyDetails.AccessKeyID != "":
+		details := resource.AccessKeyDetails
+		if details.UserName == "" {
+			return "Access key " + details.AccessKeyID
+		}
+		return fmt.Sprintf("Access key %s (%s %s)", details.AccessKeyID, details.UserType, details.UserName)
+	case len(resource.S3BucketDetails) > 0:
+		var buckets []string
+		for _, bucket := range resource.S3BucketDetails {
+			buckets = append(buckets, bucket.Name)
+		}
+		return "S3 bucket " + strings.Join(buckets, ", ")
+	default:
+		return resource.ResourceType
+	}
+}
+
+// guardDutyFindingURL links to the finding in the GuardDuty console.
+func guardDutyFindingURL(partition, region, findingID string) string {
+	return fmt.Sprintf("%s/guardduty/home?region=%s#/findings?macros=current&fId=%s",
+		consoleBaseURL(partition), url.QueryEscape(region), url.QueryEscape(findingID))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const guardDutyFindingMessage = `{
+	"version": "0",
+	"id": "c8c4daa7-a20c-2f03-0070-b7393dd542ad",
+	"detail-type": "GuardDuty Finding",
+	"source": "aws.guardduty",
+	"account": "123456789012",
+	"time": "1970-01-01T00:00:00Z",
+	"region": "us-east-1",
+	"resources": [],
+	"detail": {
+		"schemaVersion": "2.0",
+		"accountId": "123456789012",
+		"region": "us-east-1",
+		"partition": "aws",
+		"id": "16afba5c5c43e07c9e3e5e2e544e95df",
+		"arn": "arn:aws:guardduty:us-east-1:123456789012:detector/123456789012/finding/16afba5c5c43e07c9e3e5e2e544e95df",
+		"type": "UnauthorizedAccess:IAMUser/ConsoleLogin",
+		"title": "Unusual console login was seen for principal GeneratedFindingUserName.",
+		"description": "APIs commonly used in reconnaissance attacks were invoked.",
+		"severity": 5,
+		"resource": {
+			"resourceType": "AccessKey",
+			"accessKeyDetails": {"accessKeyId": "GeneratedFindingAccessKeyId", "principalId": "GeneratedFindingPrincipalId", "userName": "GeneratedFindingUserName", "userType": "IAMUser"}
+		},
+		"service": {"count": 3}
+	}
+}`
+
+func TestGuardDutyParser(t *testing.T) {
+	api := &plugintest.API{}
+	api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything).Maybe()
+	p := &Plugin{}
+	p.SetAPI(api)
+	parser := &guardDutyParser{p: p}
+
+	notification := &SNSNotification{Message: guardDutyFindingMessage}
+	require.True(t, parser.Detect(notification))
+	assert.False(t, parser.Detect(&SNSNotification{Message: `{"version": "0", "detail-type": "EC2 Instance State-change Notification", "source": "aws.ec2", "detail": {}}`}))
+
+	event, err := parser.Parse(notification)
+	require.NoError(t, err)
+
+	attachments := parser.Render(notification, event)
+	require.Len(t, attachments, 1)
+	attachment := attachments[0]
+	assert.Equal(t, "Unusual console login was seen for principal GeneratedFindingUserName.", attachment.Title)
+	assert.Equal(t, "https://console.aws.amazon.com/guardduty/home?region=us-east-1#/findings?macros=current&fId=16afba5c5c43e07c9e3e5e2e544e95df", attachment.TitleLink)
+	assert.Equal(t, colorOrange, attachment.Color)
+
+	values := map[string]string{}
+	for _, field := range attachment.Fields {
+		values[field.Title] = field.Value.(string)
+	}
+	assert.Equal(t, "UnauthorizedAccess:IAMUser/ConsoleLogin", values["Finding Type"])
+	assert.Equal(t, "Medium (5)", values["Severity"])
+	assert.Equal(t, "3", values["Count"])
+	assert.Equal(t, "Access key GeneratedFindingAccessKeyId (IAMUser GeneratedFindingUserName)", values["Resource"])
+}
+
+func TestGuardDutySeverity(t *testing.T) {
+	for severity, expected := range map[float64]string{
+		1:   "Low",
+		3.9: "Low",
+		4:   "Medium",
+		6.9: "Medium",
+		7:   "High",
+		8.9: "High",
+	} {
+		label, _ := guardDutySeverity(severity)
+		assert.Equal(t, expected, label, "severity %v", severity)
+	}
+}
+
+func TestGuardDutyResource(t *testing.T) {
+	for resource, expected := range map[string]string{
+		`{"resourceType": "Instance", "instanceDetails": {"instanceId": "i-99999999"}}`:            "Instance i-99999999",
+		`{"resourceType": "AccessKey", "accessKeyDetails": {"accessKeyId": "AKIA1"}}`:              "Access key AKIA1",
+		`{"resourceType": "S3Bucket", "s3BucketDetails": [{"name": "logs"}, {"name": "backups"}]}`: "S3 bucket logs, backups",
+		`{"resourceType": "EKSCluster"}`: "EKSCluster",
+	} {
+		var finding GuardDutyFinding
+		require.NoError(t, json.Unmarshal([]byte(`{"resource": `+resource+`}`), &finding))
+		assert.Equal(t, expected, guardDutyResource(finding))
+	}
+}
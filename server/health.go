@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	// healthThreadTTL is how long after its last update an AWS Health event keeps posting into
+	// the same thread.
+	healthThreadTTL = 30 * 24 * time.Hour
+	// maxAffectedEntities caps the number of affected entities listed on a post.
+	maxAffectedEntities = 20
+
+	healthStatusFieldTitle = "Status"
+
+	healthStatusOpen     = "open"
+	healthStatusUpcoming = "upcoming"
+	healthStatusClosed   = "closed"
+)
+
+// healthParser handles AWS Health events. The updates of an event are threaded under the post
+// of its first notification.
+type healthParser struct {
+	p *Plugin
+}
+
+func (h *healthParser) Name() string  { return "health" }
+func (h *healthParser) Priority() int { return 920 }
+
+func (h *healthParser) Detect(notification *SNSNotification) bool {
+	isHealthEvent, _ := h.p.isHealthEvent(notification.Message)
+	return isHealthEvent
+}
+
+func (h *healthParser) Parse(notification *SNSNotification) (interface{}, error) {
+	isHealthEvent, healthEvent := h.p.isHealthEvent(notification.Message)
+	if !isHealthEvent {
+		return nil, errors.New("message is not an AWS Health event")
+	}
+	return healthEvent, nil
+}
+
+func (h *healthParser) Render(notification *SNSNotification, event interface{}) []*model.SlackAttachment {
+	return []*model.SlackAttachment{h.p.createHealthEventAttachment(event.(HealthEvent))}
+}
+
+func (h *healthParser) Post(event interface{}, attachments []*model.SlackAttachment, channels []*TeamChannel) {
+	healthEvent := event.(HealthEvent)
+	for _, channel := range channels {
+		if err := h.p.postHealthEvent(healthEvent, attachments, channel); err != nil {
+			h.p.API.LogError("AWSSNS unable to post the AWS Health event", "channel_id", channel.ChannelID, "event_arn", healthEvent.EventArn, "err", err.Error())
+		}
+	}
+}
+
+func (p *Plugin) isHealthEvent(message string) (bool, HealthEvent) {
+	var healthEvent HealthEvent
+
+	event, ok := isEventBridgeEvent(message, "aws.health")
+	if !ok {
+		return false, healthEvent
+	}
+
+	if err := json.Unmarshal(event.Detail, &healthEvent); err != nil {
+		p.API.LogDebug(
+			"AWSSNS HandleNotification Decode Error on AWS Health event",
+			"err", err.Error(),
+			"message", message)
+		return false, healthEvent
+	}
+
+	return len(healthEvent.EventArn) > 0, healthEvent
+}
+
+func (p *Plugin) createHealthEventAttachment(healthEvent HealthEvent) *model.SlackAttachment {
+	var fields []*model.SlackAttachmentField
+
+	fields = addFields(fields, "Service", healthEvent.Service, true)
+	fields = addFields(fields, healthStatusFieldTitle, healthEvent.StatusCode, true)
+	fields = addFields(fields, "Event Type", healthEvent.EventTypeCode, false)
+	fields = addFields(fields, "Category", healthEvent.EventTypeCategory, true)
+	fields = addFields(fields, "Region", healthEvent.EventRegion, true)
+	fields = addFields(fields, "Start Time", healthEvent.StartTime, true)
+	if healthEvent.EndTime != "" {
+		fields = addFields(fields, "End Time", healthEvent.EndTime, true)
+	}
+	if healthEvent.AffectedAccount != "" {
+		fields = addFields(fields, "AWS Account", healthEvent.AffectedAccount, true)
+	}
+
+	var entities []string
+	for i, entity := range healthEvent.AffectedEntities {
+		if i == maxAffectedEntities {
+			entities = append(entities, fmt.Sprintf("and %d more", len(healthEvent.AffectedEntities)-maxAffectedEntities))
+			break
+		}
+		entities = append(entities, resourceLink(entity.EntityValue))
+	}
+	if len(entities) > 0 {
+		fields = addFields(fields, "Affected Entities", strings.Join(entities, "\n"), false)
+	}
+
+	return &model.SlackAttachment{
+		Title:  fmt.Sprintf("AWS Health: %s", healthEvent.EventTypeCode),
+		Text:   healthEventDescription(healthEvent),
+		Fields: fields,
+		Color:  healthStatusColor(healthEvent.StatusCode),
+		Footer: healthEvent.EventArn,
+	}
+}
+
+// postHealthEvent posts the event into the thread of its earlier updates, and shows its latest
+// status on the post that started the thread.
+func (p *Plugin) postHealthEvent(healthEvent HealthEvent, attachments []*model.SlackAttachment, channel *TeamChannel) error {
+	key := eventThreadKey(channel.ChannelID, "health", healthEvent.EventArn)
+	root, isReply, err := p.postThreaded(channel, key, attachments, healthThreadTTL)
+	if err != nil || !isReply {
+		return err
+	}
+
+	rootAttachments := root.Attachments()
+	if len(rootAttachments) == 0 {
+		return nil
+	}
+	rootAttachments[0].Color = healthStatusColor(healthEvent.StatusCode)
+	setAttachmentField(rootAttachments[0], healthStatusFieldTitle, healthEvent.StatusCode)
+
+	model.ParseSlackAttachment(root, rootAttachments)
+	if _, appErr := p.API.UpdatePost(root); appErr != nil {
+		p.API.LogWarn("AWSSNS unable to update the AWS Health thread", "post_id", root.Id, "err", appErr.Error())
+	}
+	return nil
+}
+
+// healthEventDescription returns the English description of the event, or the first one.
+func healthEventDescription(healthEvent HealthEvent) string {
+	for _, description := range healthEvent.EventDescription {
+		if description.Language == "en_US" {
+			return description.LatestDescription
+		}
+	}
+	if len(healthEvent.EventDescription) > 0 {
+		return healthEvent.EventDescription[0].LatestDescription
+	}
+	return ""
+}
+
+func healthStatusColor(status string) string {
+	switch status {
+	case healthStatusOpen:
+		return colorOrange
+	case healthStatusUpcoming:
+		return colorBlue
+	case healthStatusClosed:
+		return colorGreen
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func healthEventMessage(status string) string {
+	return `{
+		"version": "0",
+		"id": "7bf73129-1428-4cd3-a780-95db273d1602",
+		"detail-type": "AWS Health Event",
+		"source": "aws.health",
+		"account": "123456789012",
+		"time": "2023-01-27T09:01:22Z",
+		"region": "us-east-1",
+		"resources": [],
+		"detail": {
+			"eventArn": "arn:aws:health:us-east-1::event/EC2/AWS_EC2_MAINTENANCE_SCHEDULED/AWS_EC2_MAINTENANCE_SCHEDULED_1",
+			"service": "EC2",
+			"eventTypeCode": "AWS_EC2_MAINTENANCE_SCHEDULED",
+			"eventTypeCategory": "scheduledChange",
+			"startTime": "Sat, 05 Jun 2023 12:00:00 GMT",
+			"statusCode": "` + status + `",
+			"eventRegion": "us-east-1",
+			"eventDescription": [{"language": "fr_FR", "latestDescription": "Maintenance"}, {"language": "en_US", "latestDescription": "Scheduled maintenance"}],
+			"affectedEntities": [{"entityValue": "i-abcd1111"}, {"entityValue": "i-abcd2222"}]
+		}
+	}`
+}
+
+func TestHealthParserRender(t *testing.T) {
+	p := &Plugin{}
+	parser := &healthParser{p: p}
+
+	notification := &SNSNotification{Message: healthEventMessage("upcoming")}
+	require.True(t, parser.Detect(notification))
+
+	event, err := parser.Parse(notification)
+	require.NoError(t, err)
+
+	attachments := parser.Render(notification, event)
+	require.Len(t, attachments, 1)
+	assert.Equal(t, "AWS Health: AWS_EC2_MAINTENANCE_SCHEDULED", attachments[0].Title)
+	assert.Equal(t, "Scheduled maintenance", attachments[0].Text)
+	assert.Equal(t, colorBlue, attachments[0].Color)
+
+	values := map[string]string{}
+	for _, field := range attachments[0].Fields {
+		values[field.Title] = field.Value.(string)
+	}
+	assert.Equal(t, "EC2", values["Service"])
+	assert.Equal(t, "upcoming", values["Status"])
+	assert.Equal(t, "Sat, 05 Jun 2023 12:00:00 GMT", values["Start Time"])
+	assert.Equal(t, "i-abcd1111\ni-abcd2222", values["Affected Entities"])
+}
+
+func TestHealthParserPost(t *testing.T) {
+	channel := &TeamChannel{ChannelID: "channelId1"}
+	key := eventThreadKey(channel.ChannelID, "health", "arn:aws:health:us-east-1::event/EC2/AWS_EC2_MAINTENANCE_SCHEDULED/AWS_EC2_MAINTENANCE_SCHEDULED_1")
+
+	t.Run("First notification starts a thread", func(t *testing.T) {
+		api := &plugintest.API{}
+		p := &Plugin{BotUserID: "botId"}
+		p.SetAPI(api)
+		parser := &healthParser{p: p}
+
+		api.On("KVGet", key).Return(nil, nil)
+		api.On("KVSetWithOptions", key, []byte(`{"RootPostID":""}`), model.PluginKVSetOptions{
+			Atomic:          true,
+			ExpireInSeconds: int64(threadClaimTTL.Seconds()),
+		}).Return(true, nil)
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.RootId == ""
+		})).Return(&model.Post{Id: "rootId"}, nil)
+		api.On("KVSetWithExpiry", key, []byte(`{"RootPostID":"rootId"}`), int64(healthThreadTTL.Seconds())).Return(nil)
+
+		notification := &SNSNotification{Message: healthEventMessage("upcoming")}
+		event, err := parser.Parse(notification)
+		require.NoError(t, err)
+		parser.Post(event, parser.Render(notification, event), []*TeamChannel{channel})
+
+		api.AssertExpectations(t)
+	})
+
+	t.Run("Root post that fails releases the thread", func(t *testing.T) {
+		api := &plugintest.API{}
+		p := &Plugin{BotUserID: "botId"}
+		p.SetAPI(api)
+		parser := &healthParser{p: p}
+
+		api.On("KVGet", key).Return(nil, nil)
+		api.On("KVSetWithOptions", key, []byte(`{"RootPostID":""}`), mock.Anything).Return(true, nil)
+		api.On("CreatePost", mock.Anything).Return(nil, &model.AppError{Message: "failed"})
+		api.On("KVCompareAndDelete", key, []byte(`{"RootPostID":""}`)).Return(true, nil)
+		api.On("LogError", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+
+		notification := &SNSNotification{Message: healthEventMessage("upcoming")}
+		event, err := parser.Parse(notification)
+		require.NoError(t, err)
+		parser.Post(event, parser.Render(notification, event), []*TeamChannel{channel})
+
+		api.AssertExpectations(t)
+		api.AssertNotCalled(t, "KVSetWithExpiry", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Thread that can't be stored releases the claim", func(t *testing.T) {
+		api := &plugintest.API{}
+		p := &Plugin{BotUserID: "botId"}
+		p.SetAPI(api)
+		parser := &healthParser{p: p}
+
+		api.On("KVGet", key).Return(nil, nil)
+		api.On("KVSetWithOptions", key, []byte(`{"RootPostID":""}`), mock.Anything).Return(true, nil)
+		api.On("CreatePost", mock.Anything).Return(&model.Post{Id: "rootId"}, nil)
+		api.On("KVSetWithExpiry", key, []byte(`{"RootPostID":"rootId"}`), int64(healthThreadTTL.Seconds())).Return(&model.AppError{Message: "failed"})
+		api.On("KVCompareAndDelete", key, []byte(`{"RootPostID":""}`)).Return(true, nil)
+		api.On("LogError", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+
+		notification := &SNSNotification{Message: healthEventMessage("upcoming")}
+		event, err := parser.Parse(notification)
+		require.NoError(t, err)
+		parser.Post(event, parser.Render(notification, event), []*TeamChannel{channel})
+
+		api.AssertExpectations(t)
+	})
+
+	t.Run("Update is threaded and updates the root status", func(t *testing.T) {
+		api := &plugintest.API{}
+		p := &Plugin{BotUserID: "botId"}
+		p.SetAPI(api)
+		parser := &healthParser{p: p}
+
+		upcoming := &SNSNotification{Message: healthEventMessage("upcoming")}
+		upcomingEvent, err := parser.Parse(upcoming)
+		require.NoError(t, err)
+		root := &model.Post{Id: "rootId", ChannelId: channel.ChannelID}
+		model.ParseSlackAttachment(root, parser.Render(upcoming, upcomingEvent))
+
+		thread, err := json.Marshal(&EventThread{RootPostID: "rootId"})
+		require.NoError(t, err)
+		api.On("KVGet", key).Return(thread, nil)
+		api.On("GetPost", "rootId").Return(root, nil)
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.RootId == "rootId"
+		})).Return(&model.Post{Id: "replyId"}, nil)
+		api.On("KVSetWithExpiry", key, thread, int64(healthThreadTTL.Seconds())).Return(nil)
+		api.On("UpdatePost", mock.MatchedBy(func(post *model.Post) bool {
+			attachment := post.Attachments()[0]
+			for _, field := range attachment.Fields {
+				if field.Title == healthStatusFieldTitle {
+					return field.Value == "closed" && attachment.Color == colorGreen
+				}
+			}
+			return false
+		})).Return(root, nil)
+
+		notification := &SNSNotification{Message: healthEventMessage("closed")}
+		event, err := parser.Parse(notification)
+		require.NoError(t, err)
+		parser.Post(event, parser.Render(notification, event), []*TeamChannel{channel})
+
+		api.AssertExpectations(t)
+	})
+}
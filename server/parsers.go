@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// EventParser recognizes one kind of event delivered through AWS SNS and renders it for Mattermost.
+type EventParser interface {
+	// Name identifies the parser in the DisabledParsers setting and in logs.
+	Name() string
+	// Priority orders the parsers, the ones with lower values are tried first.
+	Priority() int
+	// Detect reports whether the notification carries an event the parser understands.
+	Detect(notification *SNSNotification) bool
+	// Parse decodes the event carried by the notification.
+	Parse(notification *SNSNotification) (interface{}, error)
+	// Render returns the attachments posted for the event.
+	Render(notification *SNSNotification, event interface{}) []*model.SlackAttachment
+}
+
+// ruleSubjectProvider is implemented by parsers whose events can be matched by notification rules.
+type ruleSubjectProvider interface {
+	RuleSubject(event interface{}) *ruleSubject
+}
+
+// eventPoster is implemented by parsers that post their events themselves instead of creating
+// one post per channel.
+type eventPoster interface {
+	Post(event interface{}, attachments []*model.SlackAttachment, channels []*TeamChannel)
+}
+
+// parserRegistry holds the event parsers ordered by priority.
+type parserRegistry struct {
+	parsers []EventParser
+}
+
+func newParserRegistry(parsers ...EventParser) *parserRegistry {
+	r := &parserRegistry{}
+	for _, parser := range parsers {
+		r.register(parser)
+	}
+	return r
+}
+
+// register adds a parser, keeping the registration order among parsers of equal priority.
+func (r *parserRegistry) register(parser EventParser) {
+	r.parsers = append(r.parsers, parser)
+	sort.SliceStable(r.parsers, func(i, j int) bool {
+		return r.parsers[i].Priority() < r.parsers[j].Priority()
+	})
+}
+
+// newDefaultParserRegistry returns the registry with every parser the plugin ships with.
+func (p *Plugin) newDefaultParserRegistry() *parserRegistry {
+	return newParserRegistry(
+		&cloudformationEventParser{p: p},
+		&rdsEventParser{p: p},
+		&cloudWatchAlarmParser{p: p},
+		&autoScalingParser{p: p},
+		&sesParser{p: p},
+		&s3EventParser{p: p},
+		&budgetParser{p: p},
+		&costAnomalyParser{p: p},
+		&guardDutyParser{p: p},
+		&securityHubParser{p: p},
+		&healthParser{p: p},
+		&codePipelineParser{p: p},
+		&codeBuildParser{p: p},
+		&codeDeployParser{p: p},
+		&ecsParser{p: p},
+		&eventBridgeParser{p: p},
+		&genericParser{p: p},
+	)
+}
+
+// parseNotification returns the first enabled parser that understands the notification, along
+// with the parsed event.
+func (p *Plugin) parseNotification(notification *SNSNotification) (EventParser, interface{}) {
+	disabled := p.getConfiguration().disabledParsers
+
+	for _, parser := range p.parsers.parsers {
+		if disabled[parser.Name()] || !parser.Detect(notification) {
+			continue
+		}
+
+		event, err := parser.Parse(notification)
+		if err != nil {
+			p.API.LogWarn("AWSSNS parser detected the message but could not parse it", "parser", parser.Name(), "err", err.Error())
+			continue
+		}
+		return parser, event
+	}
+
+	return nil, nil
+}
+
+// parseDisabledParsers decodes the DisabledParsers setting, a comma-separated list of parser names.
+func parseDisabledParsers(value string) map[string]bool {
+	disabled := map[string]bool{}
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			disabled[name] = true
+		}
+	}
+	return disabled
+}
+
+// genericParser accepts every message and is tried last, so messages in formats no other parser
+// recognizes are still posted.
+type genericParser struct {
+	p *Plugin
+}
+
+func (g *genericParser) Name() string                              { return "generic" }
+func (g *genericParser) Priority() int                             { return 10000 }
+func (g *genericParser) Detect(notification *SNSNotification) bool { return true }
+
+func (g *genericParser) Parse(notification *SNSNotification) (interface{}, error) {
+	return notification.Message, nil
+}
+
+func (g *genericParser) Render(notification *SNSNotification, event interface{}) []*model.SlackAttachment {
+	attachment := g.p.createSNSGenericAttachment(*notification)
+	return []*model.SlackAttachment{&attachment}
+}
+
+// createSNSGenericAttachment renders messages no other format recognized. JSON payloads are
+// pretty-printed in a code block, anything else is posted as text.
+func (p *Plugin) createSNSGenericAttachment(notification SNSNotification) model.SlackAttachment {
+	p.API.LogDebug("AWSSNS HandleNotification Generic Message", "SUBJECT", notification.Subject)
+
+	title := notification.Subject
+	if title == "" {
+		title = "AWS SNS Notification"
+	}
+
+	text := notification.Message
+	trimmed := strings.TrimSpace(notification.Message)
+	if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		var pretty bytes.Buffer
+		if err := json.Indent(&pretty, []byte(trimmed), "", "  "); err == nil {
+			text = "```json\n" + pretty.String() + "\n```"
+		}
+	}
+
+	return model.SlackAttachment{
+		Title:  title,
+		Text:   text,
+		Footer: notification.TopicArn,
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNotification(t *testing.T) {
+	alarm := `{"AlarmName": "cpu", "NewStateValue": "ALARM", "Trigger": {"Namespace": "AWS/EC2"}}`
+	rdsEvent := `{"Event Source": "db-instance", "Event Time": "2023-01-01 00:00:00.000", "Identifier Link": "https://console.aws.amazon.com/rds/home", "Source ID": "prod-db", "Event ID": "http://docs.amazonwebservices.com/AmazonRDS/latest/UserGuide/USER_Events.html#RDS-EVENT-0006", "Event Message": "DB instance restarted"}`
+	cloudformationEvent := "StackId='arn:aws:cloudformation:us-east-1:123456789012:stack/test/1'\nTimestamp='2023-01-01T00:00:00.000Z'\nEventId='test-1'\nLogicalResourceId='test'\nResourceStatus='CREATE_COMPLETE'\n"
+
+	for name, test := range map[string]struct {
+		Message         string
+		DisabledParsers string
+		ExpectedParser  string
+	}{
+		"CloudWatch alarm": {
+			Message:        alarm,
+			ExpectedParser: "cloudwatch",
+		},
+		"RDS event": {
+			Message:        rdsEvent,
+			ExpectedParser: "rds",
+		},
+		"CloudFormation event": {
+			Message:        cloudformationEvent,
+			ExpectedParser: "cloudformation",
+		},
+		"EventBridge event": {
+			Message:        `{"version": "0", "id": "1", "detail-type": "EC2 Instance State-change Notification", "source": "aws.ec2", "detail": {"state": "stopped"}}`,
+			ExpectedParser: "eventbridge",
+		},
+		"GuardDuty finding": {
+			Message:        guardDutyFindingMessage,
+			ExpectedParser: "guardduty",
+		},
+		"Security Hub findings": {
+			Message:        securityHubFindingsMessage("HIGH"),
+			ExpectedParser: "securityhub",
+		},
+		"AWS Health event": {
+			Message:        healthEventMessage("open"),
+			ExpectedParser: "health",
+		},
+		"CodePipeline state change": {
+			Message:        codePipelineExecutionMessage,
+			ExpectedParser: "codepipeline",
+		},
+		"CodeBuild state change": {
+			Message:        codeBuildMessage,
+			ExpectedParser: "codebuild",
+		},
+		"CodeDeploy state change": {
+			Message:        codeDeployMessage,
+			ExpectedParser: "codedeploy",
+		},
+		"ECS task state change": {
+			Message:        ecsTaskMessage("EssentialContainerExited", 1),
+			ExpectedParser: "ecs",
+		},
+		"Auto Scaling notification": {
+			Message:        autoScalingMessage(autoScalingTerminate, "i-1"),
+			ExpectedParser: "autoscaling",
+		},
+		"SES notification": {
+			Message:        sesBounceMessage,
+			ExpectedParser: "ses",
+		},
+		"S3 event notification": {
+			Message:        s3EventMessage,
+			ExpectedParser: "s3",
+		},
+		"AWS Budgets alert": {
+			Message:        budgetMessage,
+			ExpectedParser: "budgets",
+		},
+		"Cost anomaly": {
+			Message:        costAnomalyMessage,
+			ExpectedParser: "costanomaly",
+		},
+		"Plain text": {
+			Message:        "hello",
+			ExpectedParser: "generic",
+		},
+		"Disabled parser falls through": {
+			Message:         alarm,
+			DisabledParsers: "rds, CloudWatch",
+			ExpectedParser:  "generic",
+		},
+		"Every parser disabled": {
+			Message:         "hello",
+			DisabledParsers: "cloudformation,rds,cloudwatch,generic",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			api := &plugintest.API{}
+			api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything).Maybe()
+			api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+			api.On("LogError", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+
+			p := &Plugin{}
+			p.SetAPI(api)
+			p.parsers = p.newDefaultParserRegistry()
+			p.setConfiguration(&configuration{disabledParsers: parseDisabledParsers(test.DisabledParsers)})
+
+			parser, event := p.parseNotification(&SNSNotification{Message: test.Message})
+			if test.ExpectedParser == "" {
+				assert.Nil(t, parser)
+				return
+			}
+			require.NotNil(t, parser)
+			assert.Equal(t, test.ExpectedParser, parser.Name())
+			assert.NotNil(t, event)
+		})
+	}
+}
+
+func TestParserRegistryOrder(t *testing.T) {
+	p := &Plugin{}
+	registry := p.newDefaultParserRegistry()
+
+	var names []string
+	for _, parser := range registry.parsers {
+		names = append(names, parser.Name())
+	}
+	assert.Equal(t, []string{"cloudformation", "rds", "cloudwatch", "autoscaling", "ses", "s3", "budgets", "costanomaly", "guardduty", "securityhub", "health", "codepipeline", "codebuild", "codedeploy", "ecs", "eventbridge", "generic"}, names)
+}
@@ -1,12 +1,12 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"strconv"
 	"strings"
 	"sync"
 
@@ -15,6 +15,7 @@ import (
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/mattermost/mattermost/server/public/plugin"
 	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
 )
 
 type Plugin struct {
@@ -30,6 +31,17 @@ type Plugin struct {
 
 	BotUserID string
 	Channels  []*TeamChannel
+
+	// signingCerts caches the certificates used to verify the signature of SNS messages.
+	signingCerts *certificateCache
+	// httpClient is used for every request made to AWS SNS.
+	httpClient *http.Client
+	// parsers recognizes and renders the events carried by SNS notifications.
+	parsers *parserRegistry
+
+	// sesSummaryJob posts the SES summaries while the summary mode is on.
+	sesSummaryJob     *cluster.Job
+	sesSummaryJobLock sync.Mutex
 }
 
 type TeamChannel struct {
@@ -39,8 +51,6 @@ type TeamChannel struct {
 	ChannelName string
 }
 
-const topicsListPrefix = "topicsInChannel_"
-
 func (t *TeamChannel) String() string {
 	return fmt.Sprintf("TeamId: %s, TeamName: %s - ChannelId: %s, ChannelName: %s", t.TeamID, t.TeamName, t.ChannelID, t.ChannelName)
 }
@@ -83,6 +93,9 @@ func (p *Plugin) OnActivate() error {
 		return errors.Wrap(err, "can't ensure bot")
 	}
 	p.BotUserID = botID
+	p.httpClient = newSNSHTTPClient()
+	p.signingCerts = newCertificateCache(p.httpClient)
+	p.parsers = p.newDefaultParserRegistry()
 
 	// get or create channel if it does not exist yet and add mattermost channel id to each teamChannel
 	teamChannels, err = p.getOrCreateMattermostChannels(teamChannels)
@@ -97,6 +110,25 @@ func (p *Plugin) OnActivate() error {
 		return err
 	}
 
+	if err := p.migrateTopics(); err != nil {
+		p.API.LogError("AWSSNS unable to migrate the topics of the channels", "err", err.Error())
+	}
+
+	p.ensureSESSummaryJob()
+
+	return nil
+}
+
+func (p *Plugin) OnDeactivate() error {
+	p.sesSummaryJobLock.Lock()
+	defer p.sesSummaryJobLock.Unlock()
+
+	if p.sesSummaryJob != nil {
+		if err := p.sesSummaryJob.Close(); err != nil {
+			return errors.Wrap(err, "failed to stop the SES summary job")
+		}
+		p.sesSummaryJob = nil
+	}
 	return nil
 }
 
@@ -179,45 +211,111 @@ func (p *Plugin) IsValid(configuration *configuration) error {
 	return nil
 }
 
-func (p *Plugin) ServeHTTP(c *plugin.Context, w http.ResponseWriter, r *http.Request) {
-	if err := p.checkToken(r); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		p.API.LogError("AWSSNS TOKEN INVALID")
-		return
-	}
+// invalidChannelOrToken is the answer to requests with an unknown channel or an invalid token.
+const invalidChannelOrToken = "invalid channel or token"
 
+func (p *Plugin) ServeHTTP(c *plugin.Context, w http.ResponseWriter, r *http.Request) {
+	// tokens belong to a channel, so the channel is resolved first. Both failures get the same
+	// answer to not tell unauthenticated callers which channels are configured.
 	channel, err := p.checkChannel(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, invalidChannelOrToken, http.StatusBadRequest)
 		p.API.LogError("Channel is invalid", "error", err.Error())
 		return
 	}
 
+	if err = p.checkToken(r, channel); err != nil {
+		http.Error(w, invalidChannelOrToken, http.StatusBadRequest)
+		p.API.LogError("AWSSNS TOKEN INVALID", "error", err.Error())
+		return
+	}
+
 	snsMessageType := r.Header.Get("x-amz-sns-message-type")
 	if snsMessageType == "" {
 		p.handleAction(w, r)
-	} else {
-		switch snsMessageType {
-		case "SubscriptionConfirmation":
-			p.handleSubscriptionConfirmation(r.Body, channel)
-		case "Notification":
-			p.API.LogDebug("AWSSNS HandleNotification")
-			p.handleNotification(r.Body, channel)
-		case "UnsubscribeConfirmation":
-			p.handleUnsubscribeConfirmation(r.Body, channel)
-		default:
-			break
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSNSMessageSize))
+	if err != nil {
+		http.Error(w, "unable to read the request body", http.StatusBadRequest)
+		p.API.LogError("AWSSNS unable to read the request body", "error", err.Error())
+		return
+	}
+
+	var envelope snsEnvelope
+	if err = json.Unmarshal(body, &envelope); err != nil {
+		http.Error(w, "unable to decode the SNS message", http.StatusBadRequest)
+		p.API.LogError("AWSSNS unable to decode the SNS message", "error", err.Error())
+		return
+	}
+
+	if envelope.Type != snsMessageType {
+		http.Error(w, "message type does not match the x-amz-sns-message-type header", http.StatusBadRequest)
+		p.API.LogWarn("AWSSNS message type mismatch", "header", snsMessageType, "type", envelope.Type)
+		return
+	}
+
+	if err = p.verifySNSMessage(&envelope); err != nil {
+		http.Error(w, "invalid SNS message signature", http.StatusForbidden)
+		p.API.LogWarn("AWSSNS signature verification failed", "topic_arn", envelope.TopicArn, "error", err.Error())
+		return
+	}
+
+	// a topic token is only valid for messages of that topic
+	if topic := r.URL.Query().Get("topic"); topic != "" {
+		if !topicTokenMatches(topic, envelope.TopicArn) {
+			http.Error(w, "the token is not valid for this topic", http.StatusForbidden)
+			p.API.LogWarn("AWSSNS topic token used for another topic", "topic", topic, "topic_arn", envelope.TopicArn)
+			return
 		}
 	}
-}
-func (p *Plugin) checkToken(r *http.Request) error {
-	token := r.URL.Query().Get("token")
-	if token == "" || strings.Compare(token, p.configuration.Token) != 0 {
-		return fmt.Errorf("invalid or missing token")
+
+	if err = p.checkMessageAge(&envelope); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		p.API.LogWarn("AWSSNS rejecting stale message", "message_id", envelope.MessageID, "error", err.Error())
+		return
 	}
-	return nil
-}
 
+	// SNS delivers at least once, drop redeliveries and replayed messages
+	isNew, err := p.markMessageProcessed(channel.ChannelID, envelope.MessageID)
+	if err != nil {
+		http.Error(w, "unable to record the message", http.StatusInternalServerError)
+		p.API.LogError("AWSSNS unable to record the message id", "message_id", envelope.MessageID, "error", err.Error())
+		return
+	}
+	if !isNew {
+		p.API.LogDebug("AWSSNS dropping duplicate message", "message_id", envelope.MessageID)
+		return
+	}
+
+	switch snsMessageType {
+	case "SubscriptionConfirmation":
+		p.handleSubscriptionConfirmation(bytes.NewReader(body), channel)
+	case "Notification":
+		p.API.LogDebug("AWSSNS HandleNotification")
+		subscription, err := p.recordSubscriptionMessage(channel.ChannelID, &envelope)
+		if err != nil {
+			// without the subscription it is unknown whether it is paused, so the message is not
+			// posted now. It is forgotten, so the redelivery of AWS SNS is processed.
+			if unmarkErr := p.unmarkMessageProcessed(channel.ChannelID, envelope.MessageID); unmarkErr != nil {
+				p.API.LogError("AWSSNS unable to forget the message id", "message_id", envelope.MessageID, "error", unmarkErr.Error())
+			}
+			http.Error(w, "unable to get the subscription", http.StatusInternalServerError)
+			p.API.LogError("AWSSNS unable to get the subscription", "topic_arn", envelope.TopicArn, "err", err.Error())
+			return
+		}
+		if subscription.Paused {
+			p.API.LogDebug("AWSSNS dropping message of a paused subscription", "topic_arn", envelope.TopicArn)
+			return
+		}
+		p.handleNotification(bytes.NewReader(body), p.routeNotification(envelope.TopicArn, channel))
+	case "UnsubscribeConfirmation":
+		p.handleUnsubscribeConfirmation(bytes.NewReader(body), channel)
+	default:
+		break
+	}
+}
 func (p *Plugin) checkChannel(r *http.Request) (*TeamChannel, error) {
 	teamChannel := r.URL.Query().Get("channel")
 
@@ -234,199 +332,97 @@ func (p *Plugin) checkChannel(r *http.Request) (*TeamChannel, error) {
 	return nil, fmt.Errorf("invalid channel %s", teamChannel)
 }
 
+// getTeamChannelByID returns the configured channel with the given id, or nil.
+func (p *Plugin) getTeamChannelByID(channelID string) *TeamChannel {
+	for _, tc := range p.Channels {
+		if tc.ChannelID == channelID {
+			return tc
+		}
+	}
+	return nil
+}
+
 func (p *Plugin) handleSubscriptionConfirmation(body io.Reader, channel *TeamChannel) {
 	var subscribe SubscribeInput
 	if err := json.NewDecoder(body).Decode(&subscribe); err != nil {
 		return
 	}
 
+	if _, err := validateSNSURL(subscribe.SubscribeURL); err != nil {
+		p.API.LogWarn("AWSSNS ignoring subscription confirmation with an invalid SubscribeURL", "error", err.Error())
+		return
+	}
+
 	p.sendSubscribeConfirmationMessage(subscribe.Message, subscribe.SubscribeURL, channel)
 }
 
-func (p *Plugin) handleNotification(body io.Reader, channel *TeamChannel) {
+func (p *Plugin) handleNotification(body io.Reader, channels []*TeamChannel) {
 	var notification SNSNotification
 	if err := json.NewDecoder(body).Decode(&notification); err != nil {
 		p.API.LogDebug("AWSSNS HandleNotification Decode Error", "err=", err.Error())
 		return
 	}
 
-	if isCloudformationEvent, messageNotification := p.isCloudformationEvent(notification.Message); isCloudformationEvent {
-		p.API.LogDebug("Processing Cloudformation Event")
-		p.sendPostNotification(p.createSNSCloudformationEventAttachment(notification.Subject, messageNotification), channel)
+	parser, event := p.parseNotification(&notification)
+	if parser == nil {
+		p.API.LogDebug("AWSSNS no enabled parser recognized the message", "message_id", notification.MessageID)
 		return
 	}
+	p.API.LogDebug("AWSSNS processing message", "parser", parser.Name())
 
-	if isRdsEvent, messageNotification := p.isRDSEvent(notification.Message); isRdsEvent {
-		p.API.LogDebug("Processing RDS Event")
-		p.sendPostNotification(p.createSNSRdsEventAttachment(notification.Subject, messageNotification), channel)
-		return
-	}
-
-	if isAlarm, messageNotification := p.isCloudWatchAlarm(notification.Message); isAlarm {
-		p.API.LogDebug("Processing CloudWatch alarm")
-		p.sendPostNotification(p.createSNSMessageNotificationAttachment(notification.Subject, messageNotification), channel)
-		return
-	}
-}
+	attachments := parser.Render(&notification, event)
 
-func (p *Plugin) sendPostNotification(attachment model.SlackAttachment, channel *TeamChannel) {
-	post := &model.Post{
-		ChannelId: channel.ChannelID,
-		UserId:    p.BotUserID,
-	}
-	model.ParseSlackAttachment(post, []*model.SlackAttachment{&attachment})
-	if _, appErr := p.API.CreatePost(post); appErr != nil {
-		return
+	var dmUsers []string
+	if provider, ok := parser.(ruleSubjectProvider); ok {
+		channels, dmUsers = p.applyNotificationRules(provider.RuleSubject(event), channels)
 	}
-}
 
-func (p *Plugin) isCloudWatchAlarm(message string) (bool, SNSMessageNotification) {
-	var messageNotification SNSMessageNotification
-	if err := json.Unmarshal([]byte(message), &messageNotification); err != nil {
-		p.API.LogError(
-			"AWSSNS HandleNotification Decode Error on CloudWatch message notification",
-			"err", err.Error(),
-			"message", message)
-		return false, messageNotification
-	}
-
-	return len(messageNotification.AlarmName) > 0, messageNotification
-}
-
-func (p *Plugin) isRDSEvent(message string) (bool, SNSRdsEventNotification) {
-	var messageNotification SNSRdsEventNotification
-	if err := json.Unmarshal([]byte(message), &messageNotification); err != nil {
-		p.API.LogError(
-			"AWSSNS HandleNotification Decode Error on RDS-Event message notification",
-			"err", err.Error(),
-			"message", message)
-		return false, messageNotification
+	if poster, ok := parser.(eventPoster); ok {
+		poster.Post(event, attachments, channels)
+	} else {
+		p.sendPostNotification(attachments, channels)
 	}
-	return len(messageNotification.EventID) > 0, messageNotification
+	p.sendDirectNotification(attachments, dmUsers)
 }
 
-func (p *Plugin) isCloudformationEvent(message string) (bool, SNSCloudformationEventNotification) {
-	var messageNotification SNSCloudformationEventNotification
-
-	// alter message in order to decode it in json format
-	messagejson, err := messageToJSON(message)
-
-	if err != nil {
-		p.API.LogError(
-			"AWSSNS HandleNotification Decode Error on Cloudformation-Event message notification",
-			"err", err.Error(),
-			"message", message)
-		return false, messageNotification
-	}
-
-	if messagejson != nil {
-		if err := json.Unmarshal(messagejson, &messageNotification); err != nil {
-			p.API.LogError(
-				"AWSSNS HandleNotification Decode Error on Cloudformation-Event message notification",
-				"err", err.Error(),
-				"message", message)
-			return false, messageNotification
+func (p *Plugin) sendPostNotification(attachments []*model.SlackAttachment, channels []*TeamChannel) {
+	for _, channel := range channels {
+		post := &model.Post{
+			ChannelId: channel.ChannelID,
+			UserId:    p.BotUserID,
+		}
+		model.ParseSlackAttachment(post, attachments)
+		if _, appErr := p.API.CreatePost(post); appErr != nil {
+			p.API.LogError("AWSSNS unable to create the notification post", "channel_id", channel.ChannelID, "err", appErr.Error())
 		}
-		return len(messageNotification.EventID) > 0, messageNotification
-	}
-	return false, messageNotification
-}
-
-func (p *Plugin) createSNSRdsEventAttachment(subject string, messageNotification SNSRdsEventNotification) model.SlackAttachment {
-	p.API.LogDebug("AWSSNS HandleNotification RDS Event", "MESSAGE", subject)
-
-	var fields []*model.SlackAttachmentField
-
-	fields = addFields(fields, "Event Source", messageNotification.EventSource, true)
-	fields = addFields(fields, "Event Time", messageNotification.EventTime, true)
-	fields = addFields(fields, "Identifier Link", messageNotification.IdentifierLink, true)
-	fields = addFields(fields, "Source ID", messageNotification.SourceID, true)
-	fields = addFields(fields, "Event ID", messageNotification.EventID, true)
-	fields = addFields(fields, "Event Message", messageNotification.EventMessage, true)
-
-	attachment := model.SlackAttachment{
-		Title:  subject,
-		Fields: fields,
-	}
-
-	return attachment
-}
-
-func (p *Plugin) createSNSCloudformationEventAttachment(subject string, messageNotification SNSCloudformationEventNotification) model.SlackAttachment {
-	p.API.LogDebug("AWSSNS HandleNotification Cloudformation Event", "SUBJECT", subject)
-	var fields []*model.SlackAttachmentField
-
-	fields = addFields(fields, "StackId", messageNotification.StackID, true)
-	fields = addFields(fields, "StackName", messageNotification.StackName, true)
-	fields = addFields(fields, "LogicalResourceId", messageNotification.LogicalResourceID, true)
-	fields = addFields(fields, "PhysicalResourceId", messageNotification.PhysicalResourceID, true)
-	fields = addFields(fields, "ResourceType", messageNotification.ResourceType, true)
-	fields = addFields(fields, "Timestamp", messageNotification.Timestamp, true)
-	fields = addFields(fields, "ResourceStatus", messageNotification.ResourceStatus, true)
-
-	attachment := model.SlackAttachment{
-		Title:  subject,
-		Fields: fields,
 	}
-
-	return attachment
 }
 
-func (p *Plugin) createSNSMessageNotificationAttachment(subject string, messageNotification SNSMessageNotification) model.SlackAttachment {
-	p.API.LogDebug("AWSSNS HandleNotification", "MESSAGE", subject)
-	var fields []*model.SlackAttachmentField
-
-	fields = addFields(fields, "AlarmName", messageNotification.AlarmName, true)
-	fields = addFields(fields, "AlarmDescription", messageNotification.AlarmDescription, true)
-	fields = addFields(fields, "AWS Account", messageNotification.AWSAccountID, true)
-	fields = addFields(fields, "Region", messageNotification.Region, true)
-	fields = addFields(fields, "New State", messageNotification.NewStateValue, true)
-	fields = addFields(fields, "Old State", messageNotification.OldStateValue, true)
-	fields = addFields(fields, "New State Reason", messageNotification.NewStateReason, false)
-	fields = addFields(fields, "MetricName", messageNotification.Trigger.MetricName, true)
-	fields = addFields(fields, "Namespace", messageNotification.Trigger.Namespace, true)
-	fields = addFields(fields, "StatisticType", messageNotification.Trigger.StatisticType, true)
-	fields = addFields(fields, "Statistic", messageNotification.Trigger.Statistic, true)
-	fields = addFields(fields, "Period", strconv.Itoa(messageNotification.Trigger.Period), true)
-	fields = addFields(fields, "EvaluationPeriods", strconv.Itoa(messageNotification.Trigger.EvaluationPeriods), true)
-	fields = addFields(fields, "ComparisonOperator", messageNotification.Trigger.ComparisonOperator, true)
-	fields = addFields(fields, "Threshold", fmt.Sprintf("%f", messageNotification.Trigger.Threshold), true)
-
-	var dimensions []string
-	for _, dimension := range messageNotification.Trigger.Dimensions {
-		dimensions = append(dimensions, fmt.Sprintf("%s: %s", dimension.Name, dimension.Value))
-	}
-	fields = addFields(fields, "Dimensions", strings.Join(dimensions, "\n"), false)
-
-	msgColor := "#008000"
-	if messageNotification.NewStateValue == "ALARM" {
-		msgColor = "#FF0000"
-	} else if messageNotification.NewStateValue == "INSUFFICIENT" {
-		msgColor = "#FFFF00"
-	}
-
-	attachment := model.SlackAttachment{
-		Title:  subject,
-		Fields: fields,
-		Color:  msgColor,
-	}
-
-	return attachment
-}
 func (p *Plugin) handleUnsubscribeConfirmation(body io.Reader, channel *TeamChannel) {
 	var subscribe SubscribeInput
 	if err := json.NewDecoder(body).Decode(&subscribe); err != nil {
 		return
 	}
-	topic := strings.Split(subscribe.TopicArn, ":")[5]
-	if err := p.deleteFromKVStore(topic, channel.ChannelID); err != nil {
-		p.API.LogError("Unable to delete %s from KV Store", topic)
+	topicArn, err := parseTopicArn(subscribe.TopicArn)
+	if err != nil {
+		p.API.LogWarn("AWSSNS unsubscribe confirmation with an invalid topic", "topic_arn", subscribe.TopicArn, "err", err.Error())
+		return
+	}
+	if err := p.deleteFromKVStore(topicArn, channel.ChannelID); err != nil {
+		p.API.LogError("AWSSNS unable to delete the topic from the KV store", "topic_arn", subscribe.TopicArn, "err", err.Error())
 	}
 }
 
 func (p *Plugin) sendSubscribeConfirmationMessage(message string, subscriptionURL string, channel *TeamChannel) {
 	config := p.API.GetConfig()
 	siteURLPort := *config.ServiceSettings.SiteURL
+
+	token, err := p.getOrCreateWebhookToken(channel.ChannelID, "")
+	if err != nil {
+		p.API.LogError("AWSSNS unable to get the channel token", "err", err.Error())
+		return
+	}
+
 	action1 := &model.PostAction{
 		Name: "Confirm Subscription",
 		Type: model.PostActionTypeButton,
@@ -435,7 +431,7 @@ func (p *Plugin) sendSubscribeConfirmationMessage(message string, subscriptionUR
 				"action":           "confirm",
 				"subscription_url": subscriptionURL,
 			},
-			URL: fmt.Sprintf("%v/plugins/%v/confirm?token=%v&channel=%s", siteURLPort, manifest.Id, p.configuration.Token, channel.NameString()),
+			URL: fmt.Sprintf("%v/plugins/%v/confirm?token=%v&channel=%s", siteURLPort, manifest.Id, url.QueryEscape(token.Token), url.QueryEscape(channel.NameString())),
 		},
 	}
 
@@ -481,20 +477,39 @@ func (p *Plugin) handleAction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := p.checkAllowedUsers(action.UserID); err != nil {
-		encodeEphermalMessage(w, err.Error())
-		return
-	}
+	// the user id of the body can be set by the caller, the server sets the header
+	userID := r.Header.Get("Mattermost-User-Id")
 
 	switch r.URL.Path {
 	case "/confirm":
-		resp, err := http.Get(action.Context.SubscriptionURL)
+		if err := p.checkAllowedUsers(userID); err != nil {
+			encodeEphermalMessage(w, err.Error())
+			return
+		}
+
+		if _, err := validateSNSURL(action.Context.SubscriptionURL); err != nil {
+			encodeEphermalMessage(w, "SNS BOT Error: The subscription URL is not a valid AWS SNS URL.")
+			p.API.LogWarn("AWSSNS refusing to confirm an invalid subscription URL", "error", err.Error())
+			return
+		}
+
+		resp, err := p.httpClient.Get(action.Context.SubscriptionURL)
 		if err != nil {
 			encodeEphermalMessage(w, err.Error())
 			return
 		}
 		defer resp.Body.Close()
 
+		if resp.StatusCode != http.StatusOK {
+			encodeEphermalMessage(w, fmt.Sprintf("SNS BOT Error: AWS SNS rejected the subscription confirmation with status %d.", resp.StatusCode))
+			return
+		}
+
+		subscriptionArn, err := parseConfirmSubscriptionResponse(resp.Body)
+		if err != nil {
+			p.API.LogWarn("AWSSNS unable to read the subscription ARN", "err", err.Error())
+		}
+
 		updatePost := &model.Post{}
 		updateAttachment := &model.SlackAttachment{}
 		actionPost, errPost := p.API.GetPost(action.PostID)
@@ -503,7 +518,7 @@ func (p *Plugin) handleAction(w http.ResponseWriter, r *http.Request) {
 		} else {
 			for _, attachment := range actionPost.Attachments() {
 				if attachment.Text != "" {
-					userName, errUser := p.API.GetUser(action.UserID)
+					userName, errUser := p.API.GetUser(userID)
 					if errUser != nil {
 						updateAttachment.Text = fmt.Sprintf("%s\n**Subscription Confirmed.**", attachment.Text)
 					}
@@ -523,85 +538,41 @@ func (p *Plugin) handleAction(w http.ResponseWriter, r *http.Request) {
 			updatePost.Id = actionPost.Id
 			updatePost.ChannelId = actionPost.ChannelId
 			updatePost.UserId = actionPost.UserId
-			if _, err := p.API.UpdatePost(updatePost); err != nil {
-				encodeEphermalMessage(w, "Subscription Confirmed.")
-				return
+			if _, appErr := p.API.UpdatePost(updatePost); appErr != nil {
+				p.API.LogWarn("AWSSNS unable to update the confirmation post", "post_id", actionPost.Id, "err", appErr.Error())
 			}
 
-			encodeEphermalMessage(w, "Subscription Confirmed.")
-
 			// Extract the topic from Subscription Confirmation URL
 			query, err := url.ParseQuery(action.Context.SubscriptionURL)
 			if err != nil {
 				p.API.LogError("Unable to parse Subscribe URL from AWS SNS")
+				encodeEphermalMessage(w, "Subscription Confirmed.")
+				return
+			}
+			topicArn, err := parseTopicArn(query.Get("TopicArn"))
+			if err != nil {
+				p.API.LogError("AWSSNS subscribe URL has an invalid topic", "err", err.Error())
+				encodeEphermalMessage(w, "Subscription Confirmed.")
 				return
 			}
-			topic := strings.Split(query.Get("TopicArn"), ":")[5]
-			// Store this topic in KV Store
-			if err = p.updateKVStore(topic, actionPost.ChannelId); err != nil {
-				p.API.LogError("Unable to store AWS SNS Topic in KV Store")
+			// Store this subscription in KV Store
+			if err = p.confirmSubscription(actionPost.ChannelId, topicArn, subscriptionArn, userID); err != nil {
+				p.API.LogError("AWSSNS unable to store the subscription", "topic_arn", topicArn.String(), "err", err.Error())
+				encodeEphermalMessage(w, fmt.Sprintf("Subscription Confirmed, but it could not be recorded: %s", err.Error()))
+				return
 			}
+
+			encodeEphermalMessage(w, "Subscription Confirmed.")
 			return
 		}
+	case alarmAcknowledgePath, alarmSnoozePath, alarmResolvePath:
+		p.handleAlarmAction(w, userID, r.URL.Path, action)
 	default:
 		http.NotFound(w, r)
 		return
 	}
 }
 
-func (p *Plugin) updateKVStore(topicName string, channelID string) error {
-	var topics = SNSTopics{}
-	val, err := p.API.KVGet(topicsListPrefix + channelID)
-	if err != nil {
-		p.API.LogError("Unable to Get from KV Store")
-		return err
-	}
-	if val == nil {
-		// Initialize the map before first assignment
-		topics.Topics = make(map[string]bool)
-		topics.Topics[topicName] = true
-	} else {
-		unmarshalErr := json.Unmarshal(val, &topics)
-		if unmarshalErr != nil {
-			p.API.LogError("Unmarshal failed for existing Topics in KV Store")
-			return unmarshalErr
-		}
-		topics.Topics[topicName] = true
-	}
-	b, marshalErr := json.Marshal(topics)
-	if marshalErr != nil {
-		p.API.LogError("Unable to marshal Topics struct to JSON")
-		return marshalErr
-	}
-	p.API.KVSet(topicsListPrefix+channelID, b)
-	return nil
-}
-
-func (p *Plugin) deleteFromKVStore(topicName string, channelID string) error {
-	val, err := p.API.KVGet(topicsListPrefix + channelID)
-	if err != nil {
-		p.API.LogError("Unable to Get from KV Store")
-		return err
-	}
-	if val == nil {
-		p.API.LogError("Unexpected: No item found in KV Store")
-		return err
-	}
-	var topics SNSTopics
-	if unmarshalErr := json.Unmarshal(val, &topics); unmarshalErr != nil {
-		p.API.LogError("Failed to Unmarshal into struct")
-		return err
-	}
-	delete(topics.Topics, topicName)
-	b, marshalErr := json.Marshal(topics)
-	if marshalErr != nil {
-		p.API.LogError("Unable to Marshal the Topics struct")
-		return err
-	}
-	p.API.KVSet(topicsListPrefix+channelID, b)
-	return nil
-}
-
 func (p *Plugin) checkAllowedUsers(userID string) error {
 	if userID == "" {
 		return fmt.Errorf("need a user id")
@@ -639,46 +610,3 @@ func addFields(fields []*model.SlackAttachmentField, title, msg string, short bo
 		Short: model.SlackCompatibleBool(short),
 	})
 }
-
-func messageToJSON(message string) ([]byte, error) {
-	messagefields := strings.Split(message, "\n")
-	if len(messagefields) == 0 {
-		return nil, errors.New("no message fields present in message string")
-	}
-	// examine if the message refers to a cloudformation event by checking if a valid StackId field is included in the first line
-	stackIDParts := strings.Split(messagefields[0], "=")
-	if len(stackIDParts) == 2 && stackIDParts[0] == "StackId" {
-		containsCloudformationArn := strings.Contains(stackIDParts[1], "arn:aws:cloudformation")
-		if !containsCloudformationArn {
-			return nil, errors.New("invalid value of StackId field")
-		}
-	} else {
-		return nil, nil
-	}
-
-	var numOfFields int
-
-	// if "\n" existed at the end of the message, do not parse the last field
-	if messagefields[len(messagefields)-1] == "" {
-		numOfFields = len(messagefields) - 1
-	} else {
-		numOfFields = len(messagefields)
-	}
-
-	//split each line of the cloudformation event message to field and value
-	var fields = make(map[string]string)
-	for _, field := range messagefields[:numOfFields] {
-		parts := strings.Split(field, "=")
-		if len(parts) == 2 && parts[1] != "" {
-			fields[parts[0]] = parts[1]
-		} else {
-			return nil, errors.New("format of Cloudformation event message is incorrect")
-		}
-	}
-
-	jsonmessage, err := json.Marshal(fields)
-	if err != nil {
-		return nil, errors.Wrap(err, "Error marshaling in messageToJSON")
-	}
-	return jsonmessage, nil
-}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http/httptest"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/mattermost/mattermost/server/public/model"
@@ -122,6 +125,13 @@ func TestOnActivate(t *testing.T) {
 
 				api.On("RegisterCommand", mock.AnythingOfType("*model.Command")).Return(nil)
 
+				// topics stored before subscriptions had records are migrated
+				api.On("KVList", 0, 100).Return([]string{topicsListPrefix + "channelId1"}, nil)
+				api.On("KVGet", topicsListPrefix+"channelId1").Return([]byte(`{"Topics":{"alerts":true}}`), nil)
+				api.On("KVSetWithOptions", topicsListPrefix+"channelId1", []byte(`{"Subscriptions":{"alerts":{"TopicArn":"alerts","Paused":false}}}`),
+					model.PluginKVSetOptions{Atomic: true, OldValue: []byte(`{"Topics":{"alerts":true}}`)}).Return(true, nil)
+				api.On("LogInfo", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+
 				return api
 			},
 			TeamChannel: "team1,channel1",
@@ -159,6 +169,7 @@ func TestOnActivate(t *testing.T) {
 				}).Return(botUserID, nil)
 
 				api.On("RegisterCommand", mock.AnythingOfType("*model.Command")).Return(nil)
+				api.On("KVList", 0, 100).Return([]string{}, nil)
 
 				return api
 			},
@@ -193,3 +204,55 @@ func TestOnActivate(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleConfirmAction(t *testing.T) {
+	p := Plugin{}
+	p.SetAPI(&plugintest.API{})
+	p.setConfiguration(&configuration{AllowedUserIds: "adminId"})
+
+	// the user id of the body is not trusted, only the header set by the server
+	r := httptest.NewRequest("POST", "/confirm", strings.NewReader(`{"user_id": "adminId", "context": {"subscription_url": "https://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription"}}`))
+	r.Header.Set("Mattermost-User-Id", "someoneElse")
+	w := httptest.NewRecorder()
+	p.handleAction(w, r)
+
+	var response map[string]interface{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Contains(t, response["ephemeral_text"], "you don't have permissions")
+}
+
+func TestCreateSNSGenericAttachment(t *testing.T) {
+	api := &plugintest.API{}
+	api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything).Return()
+
+	p := Plugin{}
+	p.SetAPI(api)
+
+	for name, test := range map[string]struct {
+		Notification  SNSNotification
+		ExpectedTitle string
+		ExpectedText  string
+	}{
+		"Plain text message": {
+			Notification:  SNSNotification{Subject: "Deployment finished", Message: "api v1.2.3 is live"},
+			ExpectedTitle: "Deployment finished",
+			ExpectedText:  "api v1.2.3 is live",
+		},
+		"JSON message": {
+			Notification:  SNSNotification{Message: `{"service":"api","version":"1.2.3"}`},
+			ExpectedTitle: "AWS SNS Notification",
+			ExpectedText:  "```json\n{\n  \"service\": \"api\",\n  \"version\": \"1.2.3\"\n}\n```",
+		},
+		"Invalid JSON message": {
+			Notification:  SNSNotification{Subject: "Broken", Message: `{"service":`},
+			ExpectedTitle: "Broken",
+			ExpectedText:  `{"service":`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			attachment := p.createSNSGenericAttachment(test.Notification)
+			assert.Equal(t, test.ExpectedTitle, attachment.Title)
+			assert.Equal(t, test.ExpectedText, attachment.Text)
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+// rdsEventParser handles RDS event notifications. Events can be matched by notification rules
+// on their Source ID.
+type rdsEventParser struct {
+	p *Plugin
+}
+
+func (r *rdsEventParser) Name() string  { return "rds" }
+func (r *rdsEventParser) Priority() int { return 200 }
+
+func (r *rdsEventParser) Detect(notification *SNSNotification) bool {
+	isRdsEvent, _ := r.p.isRDSEvent(notification.Message)
+	return isRdsEvent
+}
+
+func (r *rdsEventParser) Parse(notification *SNSNotification) (interface{}, error) {
+	isRdsEvent, messageNotification := r.p.isRDSEvent(notification.Message)
+	if !isRdsEvent {
+		return nil, errors.New("message is not an RDS event")
+	}
+	return messageNotification, nil
+}
+
+func (r *rdsEventParser) Render(notification *SNSNotification, event interface{}) []*model.SlackAttachment {
+	attachment := r.p.createSNSRdsEventAttachment(notification.Subject, event.(SNSRdsEventNotification))
+	return []*model.SlackAttachment{&attachment}
+}
+
+func (r *rdsEventParser) RuleSubject(event interface{}) *ruleSubject {
+	return newRDSRuleSubject(event.(SNSRdsEventNotification))
+}
+
+func (p *Plugin) isRDSEvent(message string) (bool, SNSRdsEventNotification) {
+	var messageNotification SNSRdsEventNotification
+	if err := json.Unmarshal([]byte(message), &messageNotification); err != nil {
+		p.API.LogDebug(
+			"AWSSNS HandleNotification Decode Error on RDS-Event message notification",
+			"err", err.Error(),
+			"message", message)
+		return false, messageNotification
+	}
+	return len(messageNotification.EventID) > 0, messageNotification
+}
+
+func (p *Plugin) createSNSRdsEventAttachment(subject string, messageNotification SNSRdsEventNotification) model.SlackAttachment {
+	p.API.LogDebug("AWSSNS HandleNotification RDS Event", "MESSAGE", subject)
+
+	var fields []*model.SlackAttachmentField
+
+	fields = addFields(fields, "Event Source", messageNotification.EventSource, true)
+	fields = addFields(fields, "Event Time", messageNotification.EventTime, true)
+	fields = addFields(fields, "Identifier Link", messageNotification.IdentifierLink, true)
+	fields = addFields(fields, "Source ID", messageNotification.SourceID, true)
+	fields = addFields(fields, "Event ID", messageNotification.EventID, true)
+	fields = addFields(fields, "Event Message", messageNotification.EventMessage, true)
+
+	attachment := model.SlackAttachment{
+		Title:     subject,
+		TitleLink: rdsConsoleURL(messageNotification),
+		Fields:    fields,
+	}
+
+	return attachment
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	processedMessagePrefix = "snsMessage_"
+
+	// maxClockSkew is how far in the future a message timestamp may be before it is rejected.
+	maxClockSkew = 5 * time.Minute
+	// defaultMessageIDRetention is how long MessageIds are remembered when the maximum message
+	// age is disabled.
+	defaultMessageIDRetention = 24 * time.Hour
+)
+
+// checkMessageAge rejects messages whose signed Timestamp is older than the configured window.
+func (p *Plugin) checkMessageAge(envelope *snsEnvelope) error {
+	timestamp, err := time.Parse(time.RFC3339Nano, envelope.Timestamp)
+	if err != nil {
+		return fmt.Errorf("invalid message timestamp %q", envelope.Timestamp)
+	}
+
+	age := time.Since(timestamp)
+	if age < -maxClockSkew {
+		return fmt.Errorf("message timestamp %s is in the future", envelope.Timestamp)
+	}
+
+	if maxAge := p.maxMessageAge(); maxAge > 0 && age > maxAge {
+		return fmt.Errorf("message timestamp %s is older than %s", envelope.Timestamp, maxAge)
+	}
+
+	return nil
+}
+
+func (p *Plugin) maxMessageAge() time.Duration {
+	return time.Duration(p.getConfiguration().MaxMessageAgeMinutes) * time.Minute
+}
+
+// markMessageProcessed remembers a MessageId for the channel and reports whether it was seen
+// for the first time. The key is written atomically, so only one node of a cluster processes a
+// given message.
+func (p *Plugin) markMessageProcessed(channelID, messageID string) (bool, error) {
+	if messageID == "" {
+		return false, errors.New("message has no MessageId")
+	}
+
+	// remember the id a bit longer than the age window, so a replay is caught by either check
+	retention := defaultMessageIDRetention
+	if maxAge := p.maxMessageAge(); maxAge > 0 {
+		retention = maxAge + maxClockSkew
+	}
+
+	isNew, appErr := p.API.KVSetWithOptions(processedMessageKey(channelID, messageID), []byte(messageID), model.PluginKVSetOptions{
+		Atomic:          true,
+		OldValue:        nil,
+		ExpireInSeconds: int64(retention.Seconds()),
+	})
+	if appErr != nil {
+		return false, appErr
+	}
+	return isNew, nil
+}
+
+// unmarkMessageProcessed forgets a MessageId, so the redelivery of a message that failed is
+// processed again.
+func (p *Plugin) unmarkMessageProcessed(channelID, messageID string) error {
+	if appErr := p.API.KVDelete(processedMessageKey(channelID, messageID)); appErr != nil {
+		return appErr
+	}
+	return nil
+}
+
+func processedMessageKey(channelID, messageID string) string {
+	sum := sha256.Sum256([]byte(channelID + "/" + messageID))
+	return processedMessagePrefix + hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckMessageAge(t *testing.T) {
+	for name, test := range map[string]struct {
+		Timestamp            string
+		MaxMessageAgeMinutes int
+		ShouldError          bool
+	}{
+		"Recent message":          {Timestamp: time.Now().Add(-time.Minute).UTC().Format(time.RFC3339Nano), MaxMessageAgeMinutes: 60},
+		"Stale message":           {Timestamp: time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339Nano), MaxMessageAgeMinutes: 60, ShouldError: true},
+		"Stale message, disabled": {Timestamp: time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339Nano)},
+		"Message from the future": {Timestamp: time.Now().Add(time.Hour).UTC().Format(time.RFC3339Nano), MaxMessageAgeMinutes: 60, ShouldError: true},
+		"Invalid timestamp":       {Timestamp: "yesterday", MaxMessageAgeMinutes: 60, ShouldError: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p := Plugin{}
+			p.setConfiguration(&configuration{MaxMessageAgeMinutes: test.MaxMessageAgeMinutes})
+
+			err := p.checkMessageAge(&snsEnvelope{Timestamp: test.Timestamp})
+			if test.ShouldError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestMarkMessageProcessed(t *testing.T) {
+	stored := map[string]bool{}
+
+	api := &plugintest.API{}
+	api.On("KVSetWithOptions", mock.AnythingOfType("string"), mock.Anything, mock.Anything).Return(
+		func(key string, _ []byte, _ model.PluginKVSetOptions) bool {
+			if stored[key] {
+				return false
+			}
+			stored[key] = true
+			return true
+		}, func(string, []byte, model.PluginKVSetOptions) *model.AppError {
+			return nil
+		})
+
+	p := Plugin{}
+	p.SetAPI(api)
+	p.setConfiguration(&configuration{MaxMessageAgeMinutes: 60})
+
+	isNew, err := p.markMessageProcessed("channelId1", "message1")
+	require.NoError(t, err)
+	assert.True(t, isNew)
+
+	isNew, err = p.markMessageProcessed("channelId1", "message1")
+	require.NoError(t, err)
+	assert.False(t, isNew, "a redelivered message must be dropped")
+
+	isNew, err = p.markMessageProcessed("channelId2", "message1")
+	require.NoError(t, err)
+	assert.True(t, isNew, "the same message delivered to another channel must be processed")
+
+	api.On("KVDelete", mock.AnythingOfType("string")).Return(func(key string) *model.AppError {
+		delete(stored, key)
+		return nil
+	})
+	require.NoError(t, p.unmarkMessageProcessed("channelId1", "message1"))
+
+	isNew, err = p.markMessageProcessed("channelId1", "message1")
+	require.NoError(t, err)
+	assert.True(t, isNew, "a forgotten message must be processed again")
+}
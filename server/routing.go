@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// TopicRoute sends the notifications of matching topics to one or more channels. Empty match
+// fields match any value, TopicArn accepts * as a wildcard.
+type TopicRoute struct {
+	TopicArn  string
+	AccountID string
+	Region    string
+	// Channels uses the same format as the TeamChannel setting: teamname,channelname;teamname-2,channelname-2
+	Channels string
+
+	topicArnPattern *regexp.Regexp
+	teamChannels    []*TeamChannel
+}
+
+// parseTopicRoutes decodes the TopicRoutes setting, a JSON array of TopicRoute.
+func parseTopicRoutes(value string) ([]*TopicRoute, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var routes []*TopicRoute
+	if err := json.Unmarshal([]byte(value), &routes); err != nil {
+		return nil, errors.Wrap(err, "topicRoutes setting is not a valid JSON array")
+	}
+
+	for i, route := range routes {
+		if route == nil {
+			return nil, errors.Errorf("topicRoutes setting: route %d is empty", i)
+		}
+
+		teamChannels, err := parseTeamChannelsNames(route.Channels)
+		if err != nil || len(teamChannels) == 0 {
+			return nil, errors.Errorf("topicRoutes setting: channels of route %d don't follow the pattern $TEAM_NAME,$CHANNEL_NAME", i)
+		}
+		route.teamChannels = teamChannels
+
+		if route.TopicArn != "" {
+			route.topicArnPattern = globToRegexp(route.TopicArn)
+		}
+	}
+
+	return routes, nil
+}
+
+// matches reports whether the route applies to the given topic.
+func (r *TopicRoute) matches(topicArn string) bool {
+	parsed, err := parseTopicArn(topicArn)
+	if err != nil {
+		return false
+	}
+
+	if r.topicArnPattern != nil && !r.topicArnPattern.MatchString(topicArn) {
+		return false
+	}
+	if r.AccountID != "" && r.AccountID != parsed.AccountID {
+		return false
+	}
+	if r.Region != "" && r.Region != parsed.Region {
+		return false
+	}
+	return true
+}
+
+// routeNotification returns the channels a notification of the topic is sent to. When no route
+// matches, the channel the subscription was created for is used.
+func (p *Plugin) routeNotification(topicArn string, channel *TeamChannel) []*TeamChannel {
+	var channels []*TeamChannel
+	seen := map[string]bool{}
+
+	for _, route := range p.getConfiguration().topicRoutes {
+		if !route.matches(topicArn) {
+			continue
+		}
+
+		for _, tc := range p.resolveTeamChannels(route.teamChannels) {
+			if !seen[tc.ChannelID] {
+				seen[tc.ChannelID] = true
+				channels = append(channels, tc)
+			}
+		}
+	}
+
+	if len(channels) == 0 {
+		return []*TeamChannel{channel}
+	}
+	return channels
+}
+
+// resolveTeamChannels maps team and channel names taken from the configuration to the
+// configured channels, skipping the ones that are not configured.
+func (p *Plugin) resolveTeamChannels(teamChannels []*TeamChannel) []*TeamChannel {
+	var resolved []*TeamChannel
+	for _, teamChannel := range teamChannels {
+		tc := p.getTeamChannelByName(teamChannel.TeamName, teamChannel.ChannelName)
+		if tc == nil {
+			p.API.LogWarn("AWSSNS setting references a channel that is not configured", "channel", teamChannel.NameString())
+			continue
+		}
+		resolved = append(resolved, tc)
+	}
+	return resolved
+}
+
+// getTeamChannelByName returns the configured channel with the given team and channel names, or nil.
+func (p *Plugin) getTeamChannelByName(teamName, channelName string) *TeamChannel {
+	for _, tc := range p.Channels {
+		if tc.TeamName == teamName && tc.ChannelName == channelName {
+			return tc
+		}
+	}
+	return nil
+}
+
+// globToRegexp compiles a pattern where * matches any sequence of characters and ? a single
+// character into an anchored regular expression.
+func globToRegexp(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
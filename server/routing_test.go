@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTopicRoutes(t *testing.T) {
+	routes, err := parseTopicRoutes("")
+	assert.NoError(t, err)
+	assert.Empty(t, routes)
+
+	routes, err = parseTopicRoutes(`[{"TopicArn": "arn:aws:sns:*:*:prod-*", "Channels": "team1,channel1;team2,channel2"}]`)
+	require.NoError(t, err)
+	require.Len(t, routes, 1)
+	assert.Len(t, routes[0].teamChannels, 2)
+
+	_, err = parseTopicRoutes(`{"TopicArn": "arn:aws:sns:*:*:prod-*"}`)
+	assert.Error(t, err)
+
+	_, err = parseTopicRoutes(`[{"TopicArn": "arn:aws:sns:*:*:prod-*", "Channels": "team1"}]`)
+	assert.Error(t, err)
+}
+
+func TestRouteNotification(t *testing.T) {
+	channel1 := &TeamChannel{TeamName: "team1", ChannelName: "channel1", ChannelID: "channelId1"}
+	channel2 := &TeamChannel{TeamName: "team1", ChannelName: "channel2", ChannelID: "channelId2"}
+	channel3 := &TeamChannel{TeamName: "team2", ChannelName: "channel3", ChannelID: "channelId3"}
+
+	routes, err := parseTopicRoutes(`[
+		{"TopicArn": "arn:aws:sns:*:*:prod-*", "Channels": "team1,channel2"},
+		{"AccountID": "123456789012", "Region": "eu-west-1", "Channels": "team2,channel3;team1,channel2"},
+		{"TopicArn": "arn:aws:sns:us-east-1:*:unknown", "Channels": "team3,channel4"}
+	]`)
+	require.NoError(t, err)
+
+	api := &plugintest.API{}
+	api.On("LogWarn", mock.Anything, mock.Anything, mock.Anything).Return()
+
+	p := Plugin{Channels: []*TeamChannel{channel1, channel2, channel3}}
+	p.SetAPI(api)
+	p.setConfiguration(&configuration{topicRoutes: routes})
+
+	for name, test := range map[string]struct {
+		TopicArn string
+		Expected []*TeamChannel
+	}{
+		"Matching topic":                   {TopicArn: "arn:aws:sns:us-east-1:111111111111:prod-db", Expected: []*TeamChannel{channel2}},
+		"Matching account and region":      {TopicArn: "arn:aws:sns:eu-west-1:123456789012:staging", Expected: []*TeamChannel{channel3, channel2}},
+		"Several routes matching":          {TopicArn: "arn:aws:sns:eu-west-1:123456789012:prod-db", Expected: []*TeamChannel{channel2, channel3}},
+		"No route matching":                {TopicArn: "arn:aws:sns:us-east-1:111111111111:staging", Expected: []*TeamChannel{channel1}},
+		"Route to an unconfigured channel": {TopicArn: "arn:aws:sns:us-east-1:111111111111:unknown", Expected: []*TeamChannel{channel1}},
+		"Malformed topic ARN":              {TopicArn: "prod-db", Expected: []*TeamChannel{channel1}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.Expected, p.routeNotification(test.TopicArn, channel1))
+		})
+	}
+}
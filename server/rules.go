@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	ruleActionRoute = "route"
+	ruleActionDrop  = "drop"
+)
+
+// NotificationRule matches the content of a notification and decides where it is posted.
+// Patterns are globs, or regular expressions when wrapped in slashes, e.g. /^prod-(db|api)$/.
+// Empty patterns match any value and all set patterns must match.
+type NotificationRule struct {
+	Name string
+
+	AlarmName     string
+	NewStateValue string
+	Namespace     string
+	Region        string
+	SourceID      string
+	// Dimensions maps a dimension name to the pattern its value must match.
+	Dimensions map[string]string
+
+	// Action is "route" (the default), which posts to Channels instead of the routed channels,
+	// or "drop", which posts to no channel at all.
+	Action string
+	// Channels uses the same format as the TeamChannel setting. When empty, a "route" rule keeps
+	// the routed channels.
+	Channels string
+	// DMUsers is a comma-separated list of usernames that also get the notification as a direct message.
+	DMUsers string
+
+	patterns     map[string]*regexp.Regexp
+	dimensions   map[string]*regexp.Regexp
+	teamChannels []*TeamChannel
+	dmUsers      []string
+}
+
+// ruleSubject holds the fields of a notification the rules are evaluated against.
+type ruleSubject struct {
+	AlarmName     string
+	NewStateValue string
+	Namespaces    []string
+	Region        string
+	SourceID      string
+	Dimensions    map[string]string
+}
+
+func newAlarmRuleSubject(messageNotification SNSMessageNotification) *ruleSubject {
+	subject := &ruleSubject{
+		AlarmName:     messageNotification.AlarmName,
+		NewStateValue: messageNotification.NewStateValue,
+		Region:        messageNotification.Region,
+		Dimensions:    map[string]string{},
+	}
+	if messageNotification.Trigger.Namespace != "" {
+		subject.Namespaces = append(subject.Namespaces, messageNotification.Trigger.Namespace)
+	}
+	for _, dimension := range messageNotification.Trigger.Dimensions {
+		subject.Dimensions[dimension.Name] = dimension.Value
+	}
+	// Metric math alarms carry their namespaces and dimensions on the individual metrics.
+	for _, query := range messageNotification.Trigger.Metrics {
+		if query.MetricStat == nil {
+			continue
+		}
+		metric := query.MetricStat.Metric
+		if metric.Namespace != "" && !slices.Contains(subject.Namespaces, metric.Namespace) {
+			subject.Namespaces = append(subject.Namespaces, metric.Namespace)
+		}
+		for _, dimension := range metric.Dimensions {
+			subject.Dimensions[dimension.Name] = dimension.Value
+		}
+	}
+	return subject
+}
+
+func newRDSRuleSubject(messageNotification SNSRdsEventNotification) *ruleSubject {
+	return &ruleSubject{
+		SourceID: messageNotification.SourceID,
+	}
+}
+
+// parseNotificationRules decodes the NotificationRules setting, a JSON array of NotificationRule.
+func parseNotificationRules(value string) ([]*NotificationRule, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var rules []*NotificationRule
+	if err := json.Unmarshal([]byte(value), &rules); err != nil {
+		return nil, errors.Wrap(err, "notificationRules setting is not a valid JSON array")
+	}
+
+	for i, rule := range rules {
+		if rule == nil {
+			return nil, errors.Errorf("notificationRules setting: rule %d is empty", i)
+		}
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule %d", i)
+		}
+		if err := rule.compile(); err != nil {
+			return nil, errors.Wrapf(err, "notificationRules setting: %s", rule.Name)
+		}
+	}
+
+	return rules, nil
+}
+
+func (r *NotificationRule) compile() error {
+	switch r.Action {
+	case "":
+		r.Action = ruleActionRoute
+	case ruleActionRoute, ruleActionDrop:
+	default:
+		return errors.Errorf("unknown action %q", r.Action)
+	}
+
+	r.patterns = map[string]*regexp.Regexp{}
+	for field, pattern := range map[string]string{
+		"AlarmName":     r.AlarmName,
+		"NewStateValue": r.NewStateValue,
+		"Namespace":     r.Namespace,
+		"Region":        r.Region,
+		"SourceID":      r.SourceID,
+	} {
+		if pattern == "" {
+			continue
+		}
+		compiled, err := compilePattern(pattern)
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s pattern", field)
+		}
+		r.patterns[field] = compiled
+	}
+
+	r.dimensions = map[string]*regexp.Regexp{}
+	for name, pattern := range r.Dimensions {
+		compiled, err := compilePattern(pattern)
+		if err != nil {
+			return errors.Wrapf(err, "invalid pattern for dimension %s", name)
+		}
+		r.dimensions[name] = compiled
+	}
+
+	if r.Channels != "" {
+		teamChannels, err := parseTeamChannelsNames(r.Channels)
+		if err != nil {
+			return errors.New("channels don't follow the pattern $TEAM_NAME,$CHANNEL_NAME")
+		}
+		r.teamChannels = teamChannels
+	}
+
+	for _, username := range strings.Split(r.DMUsers, ",") {
+		if username = strings.TrimPrefix(strings.TrimSpace(username), "@"); username != "" {
+			r.dmUsers = append(r.dmUsers, username)
+		}
+	}
+
+	return nil
+}
+
+// compilePattern compiles /regex/ patterns as regular expressions and everything else as a glob.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) >= 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		return regexp.Compile(pattern[1 : len(pattern)-1])
+	}
+	return globToRegexp(pattern), nil
+}
+
+func (r *NotificationRule) matches(subject *ruleSubject) bool {
+	for field, pattern := range r.patterns {
+		var matched bool
+		switch field {
+		case "AlarmName":
+			matched = pattern.MatchString(subject.AlarmName)
+		case "NewStateValue":
+			matched = pattern.MatchString(subject.NewStateValue)
+		case "Namespace":
+			for _, namespace := range subject.Namespaces {
+				if pattern.MatchString(namespace) {
+					matched = true
+					break
+				}
+			}
+		case "Region":
+			matched = pattern.MatchString(subject.Region)
+		case "SourceID":
+			matched = pattern.MatchString(subject.SourceID)
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	for name, pattern := range r.dimensions {
+		value, ok := subject.Dimensions[name]
+		if !ok || !pattern.MatchString(value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// applyNotificationRules evaluates the rules in order and returns the channels the notification
+// is posted to, along with the users who get it as a direct message. The first matching rule
+// wins. In dry-run mode the match is only logged and the routed channels are returned as is.
+func (p *Plugin) applyNotificationRules(subject *ruleSubject, channels []*TeamChannel) ([]*TeamChannel, []string) {
+	config := p.getConfiguration()
+
+	for _, rule := range config.notificationRules {
+		if !rule.matches(subject) {
+			continue
+		}
+
+		if config.NotificationRulesDryRun {
+			p.API.LogInfo("AWSSNS notification rule would have matched",
+				"rule", rule.Name,
+				"action", rule.Action,
+				"channels", rule.Channels,
+				"dm_users", rule.DMUsers,
+				"alarm_name", subject.AlarmName,
+				"source_id", subject.SourceID,
+			)
+			return channels, nil
+		}
+
+		p.API.LogDebug("AWSSNS notification rule matched", "rule", rule.Name, "action", rule.Action)
+		switch rule.Action {
+		case ruleActionDrop:
+			return nil, rule.dmUsers
+		default:
+			if len(rule.teamChannels) == 0 {
+				return channels, rule.dmUsers
+			}
+			return p.resolveTeamChannels(rule.teamChannels), rule.dmUsers
+		}
+	}
+
+	return channels, nil
+}
+
+// sendDirectNotification sends the attachments as a direct message from the bot to each user.
+func (p *Plugin) sendDirectNotification(attachments []*model.SlackAttachment, usernames []string) {
+	for _, username := range usernames {
+		user, appErr := p.API.GetUserByUsername(username)
+		if appErr != nil {
+			p.API.LogWarn("AWSSNS unable to find the user to notify", "username", username, "err", appErr.Error())
+			continue
+		}
+
+		channel, appErr := p.API.GetDirectChannel(user.Id, p.BotUserID)
+		if appErr != nil {
+			p.API.LogError("AWSSNS unable to get the direct channel", "username", username, "err", appErr.Error())
+			continue
+		}
+
+		post := &model.Post{
+			ChannelId: channel.Id,
+			UserId:    p.BotUserID,
+		}
+		model.ParseSlackAttachment(post, attachments)
+		if _, appErr := p.API.CreatePost(post); appErr != nil {
+			p.API.LogError("AWSSNS unable to create the direct message", "username", username, "err", appErr.Error())
+		}
+	}
+}
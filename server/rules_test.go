@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNotificationRules(t *testing.T) {
+	rules, err := parseNotificationRules(`[{"AlarmName": "prod-*", "Action": "drop"}]`)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	assert.Equal(t, "rule 0", rules[0].Name)
+
+	_, err = parseNotificationRules(`[{"AlarmName": "prod-*", "Action": "escalate"}]`)
+	assert.Error(t, err)
+
+	_, err = parseNotificationRules(`[{"AlarmName": "/prod-(/"}]`)
+	assert.Error(t, err)
+
+	_, err = parseNotificationRules(`[{"AlarmName": "prod-*", "Channels": "team1"}]`)
+	assert.Error(t, err)
+}
+
+func TestApplyNotificationRules(t *testing.T) {
+	channel1 := &TeamChannel{TeamName: "team1", ChannelName: "channel1", ChannelID: "channelId1"}
+	channel2 := &TeamChannel{TeamName: "team1", ChannelName: "channel2", ChannelID: "channelId2"}
+
+	rules, err := parseNotificationRules(`[
+		{"Name": "test alarms", "AlarmName": "test-*", "Action": "drop"},
+		{"Name": "prod databases", "Namespace": "AWS/RDS", "NewStateValue": "ALARM", "Dimensions": {"DBInstanceIdentifier": "/^prod-db-[0-9]+$/"}, "Channels": "team1,channel2", "DMUsers": "alice, @bob"},
+		{"Name": "rds events", "SourceID": "prod-*", "DMUsers": "carol"}
+	]`)
+	require.NoError(t, err)
+
+	alarm := func(message string) *ruleSubject {
+		var messageNotification SNSMessageNotification
+		require.NoError(t, json.Unmarshal([]byte(message), &messageNotification))
+		return newAlarmRuleSubject(messageNotification)
+	}
+
+	for name, test := range map[string]struct {
+		Subject          *ruleSubject
+		DryRun           bool
+		ExpectedChannels []*TeamChannel
+		ExpectedDMUsers  []string
+	}{
+		"Dropped alarm": {
+			Subject: alarm(`{"AlarmName": "test-cpu", "NewStateValue": "ALARM"}`),
+		},
+		"Routed alarm": {
+			Subject:          alarm(`{"AlarmName": "db-cpu", "NewStateValue": "ALARM", "Trigger": {"Namespace": "AWS/RDS", "Dimensions": [{"name": "DBInstanceIdentifier", "value": "prod-db-1"}]}}`),
+			ExpectedChannels: []*TeamChannel{channel2},
+			ExpectedDMUsers:  []string{"alice", "bob"},
+		},
+		"Dimension not matching": {
+			Subject:          alarm(`{"AlarmName": "db-cpu", "NewStateValue": "ALARM", "Trigger": {"Namespace": "AWS/RDS", "Dimensions": [{"name": "DBInstanceIdentifier", "value": "staging-db-1"}]}}`),
+			ExpectedChannels: []*TeamChannel{channel1},
+		},
+		"Routed metric math alarm": {
+			Subject:          alarm(`{"AlarmName": "db-cpu", "NewStateValue": "ALARM", "Trigger": {"Metrics": [{"Id": "e1", "Expression": "m1 * 2", "ReturnData": true}, {"Id": "m1", "MetricStat": {"Metric": {"Namespace": "AWS/RDS", "MetricName": "CPUUtilization", "Dimensions": [{"name": "DBInstanceIdentifier", "value": "prod-db-2"}]}, "Period": 60, "Stat": "Average"}}]}}`),
+			ExpectedChannels: []*TeamChannel{channel2},
+			ExpectedDMUsers:  []string{"alice", "bob"},
+		},
+		"RDS event": {
+			Subject:          newRDSRuleSubject(SNSRdsEventNotification{SourceID: "prod-db-1", EventID: "RDS-EVENT-0006"}),
+			ExpectedChannels: []*TeamChannel{channel1},
+			ExpectedDMUsers:  []string{"carol"},
+		},
+		"Dry run": {
+			Subject:          alarm(`{"AlarmName": "test-cpu", "NewStateValue": "ALARM"}`),
+			DryRun:           true,
+			ExpectedChannels: []*TeamChannel{channel1},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			api := &plugintest.API{}
+			api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+			api.On("LogInfo", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+				mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+
+			p := Plugin{Channels: []*TeamChannel{channel1, channel2}}
+			p.SetAPI(api)
+			p.setConfiguration(&configuration{notificationRules: rules, NotificationRulesDryRun: test.DryRun})
+
+			channels, dmUsers := p.applyNotificationRules(test.Subject, []*TeamChannel{channel1})
+			assert.Equal(t, test.ExpectedChannels, channels)
+			assert.Equal(t, test.ExpectedDMUsers, dmUsers)
+		})
+	}
+}
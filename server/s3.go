@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const s3TestEvent = "s3:TestEvent"
+
+// s3EventParser handles S3 event notifications, as well as the test event S3 sends when a
+// notification is configured.
+type s3EventParser struct {
+	p *Plugin
+}
+
+func (s *s3EventParser) Name() string  { return "s3" }
+func (s *s3EventParser) Priority() int { return 420 }
+
+func (s *s3EventParser) Detect(notification *SNSNotification) bool {
+	isS3Event, _ := s.p.isS3Event(notification.Message)
+	return isS3Event
+}
+
+func (s *s3EventParser) Parse(notification *SNSNotification) (interface{}, error) {
+	isS3Event, messageNotification := s.p.isS3Event(notification.Message)
+	if !isS3Event {
+		return nil, errors.New("message is not an S3 event notification")
+	}
+	return messageNotification, nil
+}
+
+func (s *s3EventParser) Render(notification *SNSNotification, event interface{}) []*model.SlackAttachment {
+	return s.p.createSNSS3EventAttachments(event.(SNSS3EventNotification))
+}
+
+func (p *Plugin) isS3Event(message string) (bool, SNSS3EventNotification) {
+	var messageNotification SNSS3EventNotification
+	if err := json.Unmarshal([]byte(message), &messageNotification); err != nil {
+		p.API.LogDebug(
+			"AWSSNS HandleNotification Decode Error on S3 event notification",
+			"err", err.Error(),
+			"message", message)
+		return false, messageNotification
+	}
+
+	if messageNotification.Event == s3TestEvent {
+		return true, messageNotification
+	}
+	if len(messageNotification.Records) == 0 {
+		return false, messageNotification
+	}
+	for _, record := range messageNotification.Records {
+		if record.EventSource != "aws:s3" {
+			return false, messageNotification
+		}
+	}
+	return true, messageNotification
+}
+
+// createSNSS3EventAttachments renders every record of the notification as its own attachment.
+func (p *Plugin) createSNSS3EventAttachments(messageNotification SNSS3EventNotification) []*model.SlackAttachment {
+	p.API.LogDebug("AWSSNS HandleNotification S3 Event", "RECORDS", strconv.Itoa(len(messageNotification.Records)))
+
+	if messageNotification.Event == s3TestEvent {
+		var fields []*model.SlackAttachmentField
+		fields = addFields(fields, "Bucket", messageNotification.Bucket, true)
+		fields = addFields(fields, "Time", messageNotification.Time, true)
+		return []*model.SlackAttachment{{
+			Title:  "Amazon S3 test event",
+			Text:   "Event notifications of the bucket are now sent to this channel.",
+			Fields: fields,
+		}}
+	}
+
+	var attachments []*model.SlackAttachment
+	for _, record := range messageNotification.Records {
+		// object keys are URL encoded, with spaces encoded as +
+		key, err := url.QueryUnescape(record.S3.Object.Key)
+		if err != nil {
+			key = record.S3.Object.Key
+		}
+
+		var fields []*model.SlackAttachmentField
+		fields = addFields(fields, "Bucket", record.S3.Bucket.Name, true)
+		fields = addFields(fields, "Event", record.EventName, true)
+		fields = addFields(fields, "Key", key, false)
+		if strings.HasPrefix(record.EventName, "ObjectCreated:") {
+			fields = addFields(fields, "Size", formatBytes(record.S3.Object.Size), true)
+		}
+		fields = addFields(fields, "Principal", record.UserIdentity.PrincipalID, true)
+		if record.RequestParameters.SourceIPAddress != "" {
+			fields = addFields(fields, "Source IP", record.RequestParameters.SourceIPAddress, true)
+		}
+		fields = addFields(fields, "Region", record.AWSRegion, true)
+		fields = addFields(fields, "Event Time", record.EventTime, true)
+
+		attachment := &model.SlackAttachment{
+			Title:     fmt.Sprintf("Amazon S3 %s", record.EventName),
+			TitleLink: s3ConsoleURL(record.S3.Bucket.Arn, record.AWSRegion, record.S3.Bucket.Name, key, record.EventName),
+			Fields:    fields,
+		}
+		switch {
+		case strings.HasPrefix(record.EventName, "ObjectCreated:"):
+			attachment.Color = colorGreen
+		case strings.HasPrefix(record.EventName, "ObjectRemoved:"):
+			attachment.Color = colorYellow
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments
+}
+
+// s3ConsoleURL links to the object in the S3 console, or to its bucket when the object was removed.
+func s3ConsoleURL(bucketArn, region, bucket, key, eventName string) string {
+	partition := arnPartition(bucketArn)
+
+	query := url.Values{}
+	query.Set("region", region)
+	if strings.HasPrefix(eventName, "ObjectRemoved:") {
+		return fmt.Sprintf("%s/s3/buckets/%s?%s", consoleBaseURL(partition), url.PathEscape(bucket), query.Encode())
+	}
+	query.Set("prefix", key)
+	return fmt.Sprintf("%s/s3/object/%s?%s", consoleBaseURL(partition), url.PathEscape(bucket), query.Encode())
+}
+
+// formatBytes renders a size in bytes with a binary unit, e.g. 1.5 KiB.
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
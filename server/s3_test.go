@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const s3EventMessage = `{
+	"Records": [
+		{
+			"eventVersion": "2.1",
+			"eventSource": "aws:s3",
+			"awsRegion": "us-west-2",
+			"eventTime": "2023-02-15T20:20:12.123Z",
+			"eventName": "ObjectCreated:Put",
+			"userIdentity": {"principalId": "AWS:AIDAEXAMPLE"},
+			"requestParameters": {"sourceIPAddress": "203.0.113.10"},
+			"s3": {
+				"bucket": {"name": "my-bucket", "arn": "arn:aws:s3:::my-bucket"},
+				"object": {"key": "reports/2023+Q1.csv", "size": 1536, "eTag": "0123456789abcdef"}
+			}
+		},
+		{
+			"eventVersion": "2.1",
+			"eventSource": "aws:s3",
+			"awsRegion": "us-west-2",
+			"eventTime": "2023-02-15T20:20:13.456Z",
+			"eventName": "ObjectRemoved:Delete",
+			"userIdentity": {"principalId": "AWS:AIDAEXAMPLE"},
+			"s3": {
+				"bucket": {"name": "my-bucket", "arn": "arn:aws:s3:::my-bucket"},
+				"object": {"key": "old.csv"}
+			}
+		}
+	]
+}`
+
+func TestS3EventParser(t *testing.T) {
+	api := &plugintest.API{}
+	api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything).Maybe()
+	p := &Plugin{}
+	p.SetAPI(api)
+	parser := &s3EventParser{p: p}
+
+	t.Run("Records", func(t *testing.T) {
+		notification := &SNSNotification{Message: s3EventMessage}
+		require.True(t, parser.Detect(notification))
+
+		event, err := parser.Parse(notification)
+		require.NoError(t, err)
+		attachments := parser.Render(notification, event)
+		require.Len(t, attachments, 2)
+
+		assert.Equal(t, "Amazon S3 ObjectCreated:Put", attachments[0].Title)
+		assert.Equal(t, "https://console.aws.amazon.com/s3/object/my-bucket?prefix=reports%2F2023+Q1.csv&region=us-west-2", attachments[0].TitleLink)
+		assert.Equal(t, colorGreen, attachments[0].Color)
+		values := renderFields(attachments[0])
+		assert.Equal(t, "my-bucket", values["Bucket"])
+		assert.Equal(t, "reports/2023 Q1.csv", values["Key"])
+		assert.Equal(t, "1.5 KiB", values["Size"])
+		assert.Equal(t, "AWS:AIDAEXAMPLE", values["Principal"])
+		assert.Equal(t, "203.0.113.10", values["Source IP"])
+
+		assert.Equal(t, "Amazon S3 ObjectRemoved:Delete", attachments[1].Title)
+		assert.Equal(t, "https://console.aws.amazon.com/s3/buckets/my-bucket?region=us-west-2", attachments[1].TitleLink)
+		assert.Equal(t, colorYellow, attachments[1].Color)
+		assert.NotContains(t, renderFields(attachments[1]), "Size")
+	})
+
+	t.Run("Test event", func(t *testing.T) {
+		notification := &SNSNotification{Message: `{"Service": "Amazon S3", "Event": "s3:TestEvent", "Time": "2023-02-15T20:20:12.123Z", "Bucket": "my-bucket", "RequestId": "5582815E1AEA5ADF", "HostId": "8cLeGAmw098X5cv4Zkwcmo8vvZa3eH3eKxsPzbB9wrR+YstdA6Knx4Ip8EXAMPLE"}`}
+		require.True(t, parser.Detect(notification))
+
+		event, err := parser.Parse(notification)
+		require.NoError(t, err)
+		attachments := parser.Render(notification, event)
+		require.Len(t, attachments, 1)
+		assert.Equal(t, "Amazon S3 test event", attachments[0].Title)
+		assert.Equal(t, "my-bucket", renderFields(attachments[0])["Bucket"])
+	})
+
+	t.Run("Other records", func(t *testing.T) {
+		assert.False(t, parser.Detect(&SNSNotification{Message: `{"Records": [{"eventSource": "aws:ses"}]}`}))
+		assert.False(t, parser.Detect(&SNSNotification{Message: `{"AlarmName": "cpu"}`}))
+	})
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "0 B", formatBytes(0))
+	assert.Equal(t, "1023 B", formatBytes(1023))
+	assert.Equal(t, "1.0 KiB", formatBytes(1024))
+	assert.Equal(t, "5.0 MiB", formatBytes(5*1024*1024))
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+// maxFindingsPerPost is the largest batch of Security Hub findings posted as a single post.
+// Larger batches get a summary post with the findings in its thread.
+const maxFindingsPerPost = 5
+
+// securityHubSeverities lists the ASFF severity labels from the most to the least severe.
+var securityHubSeverities = []string{"CRITICAL", "HIGH", "MEDIUM", "LOW", "INFORMATIONAL"}
+
+// securityHubParser handles batches of findings imported into Security Hub.
+type securityHubParser struct {
+	p *Plugin
+}
+
+func (s *securityHubParser) Name() string  { return "securityhub" }
+func (s *securityHubParser) Priority() int { return 910 }
+
+func (s *securityHubParser) Detect(notification *SNSNotification) bool {
+	isFindings, _ := s.p.isSecurityHubFindings(notification.Message)
+	return isFindings
+}
+
+func (s *securityHubParser) Parse(notification *SNSNotification) (interface{}, error) {
+	isFindings, findings := s.p.isSecurityHubFindings(notification.Message)
+	if !isFindings {
+		return nil, errors.New("message is not a batch of Security Hub findings")
+	}
+	return findings, nil
+}
+
+func (s *securityHubParser) Render(notification *SNSNotification, event interface{}) []*model.SlackAttachment {
+	var attachments []*model.SlackAttachment
+	for _, finding := range event.(SecurityHubFindingsImported).Findings {
+		attachments = append(attachments, s.p.createSecurityHubFindingAttachment(finding))
+	}
+	return attachments
+}
+
+// Post sends small batches as a single post. Larger ones get a summary post, with the findings
+// split over replies in its thread.
+func (s *securityHubParser) Post(event interface{}, attachments []*model.SlackAttachment, channels []*TeamChannel) {
+	if len(attachments) <= maxFindingsPerPost {
+		s.p.sendPostNotification(attachments, channels)
+		return
+	}
+
+	summary := createSecurityHubSummaryAttachment(event.(SecurityHubFindingsImported))
+	for _, channel := range channels {
+		if err := s.p.postSecurityHubBatch(summary, attachments, channel); err != nil {
+			s.p.API.LogError("AWSSNS unable to post the Security Hub findings", "channel_id", channel.ChannelID, "err", err.Error())
+		}
+	}
+}
+
+func (p *Plugin) isSecurityHubFindings(message string) (bool, SecurityHubFindingsImported) {
+	var findings SecurityHubFindingsImported
+
+	event, ok := isEventBridgeEvent(message, "aws.securityhub", "Security Hub Findings - Imported")
+	if !ok {
+		return false, findings
+	}
+
+	if err := json.Unmarshal(event.Detail, &findings); err != nil {
+		p.API.LogDebug(
+			"AWSSNS HandleNotification Decode Error on Security Hub findings",
+			"err", err.Error(),
+			"message", message)
+		return false, findings
+	}
+
+	return len(findings.Findings) > 0, findings
+}
+
+func (p *Plugin) createSecurityHubFindingAttachment(finding SecurityHubFinding) *model.SlackAttachment {
+	var fields []*model.SlackAttachmentField
+
+	product := finding.ProductName
+	if product == "" {
+		product = finding.ProductArn
+	}
+
+	var resources []string
+	for _, resource := range finding.Resources {
+		resources = append(resources, fmt.Sprintf("%s %s", resource.Type, resourceLink(resource.ID)))
+	}
+
+	fields = addFields(fields, "Severity", finding.Severity.Label, true)
+	fields = addFields(fields, "Compliance Status", finding.Compliance.Status, true)
+	fields = addFields(fields, "Product", product, true)
+	fields = addFields(fields, "AWS Account", finding.AWSAccountID, true)
+	fields = addFields(fields, "Region", finding.Region, true)
+	fields = addFields(fields, "Workflow Status", finding.Workflow.Status, true)
+	fields = addFields(fields, "Resources", strings.Join(resources, "\n"), false)
+
+	return &model.SlackAttachment{
+		Title:  finding.Title,
+		Text:   finding.Description,
+		Fields: fields,
+		Color:  securityHubSeverityColor(finding.Severity.Label),
+		Footer: finding.ID,
+	}
+}
+
+// createSecurityHubSummaryAttachment counts the findings of a batch by severity.
+func createSecurityHubSummaryAttachment(findings SecurityHubFindingsImported) *model.SlackAttachment {
+	counts := map[string]int{}
+	for _, finding := range findings.Findings {
+		counts[finding.Severity.Label]++
+	}
+
+	var fields []*model.SlackAttachmentField
+	color := ""
+	for _, label := range securityHubSeverities {
+		if counts[label] == 0 {
+			continue
+		}
+		if color == "" {
+			color = securityHubSeverityColor(label)
+		}
+		fields = addFields(fields, label, strconv.Itoa(counts[label]), true)
+	}
+
+	return &model.SlackAttachment{
+		Title:  fmt.Sprintf("%d Security Hub findings imported", len(findings.Findings)),
+		Text:   "The findings are listed in the thread of this post.",
+		Fields: fields,
+		Color:  color,
+	}
+}
+
+// postSecurityHubBatch posts the summary of a batch, then the findings as replies to it.
+func (p *Plugin) postSecurityHubBatch(summary *model.SlackAttachment, attachments []*model.SlackAttachment, channel *TeamChannel) error {
+	root := &model.Post{
+		ChannelId: channel.ChannelID,
+		UserId:    p.BotUserID,
+	}
+	model.ParseSlackAttachment(root, []*model.SlackAttachment{summary})
+	created, appErr := p.API.CreatePost(root)
+	if appErr != nil {
+		return appErr
+	}
+
+	for start := 0; start < len(attachments); start += maxFindingsPerPost {
+		end := start + maxFindingsPerPost
+		if end > len(attachments) {
+			end = len(attachments)
+		}
+
+		reply := &model.Post{
+			ChannelId: channel.ChannelID,
+			UserId:    p.BotUserID,
+			RootId:    created.Id,
+		}
+		model.ParseSlackAttachment(reply, attachments[start:end])
+		if _, appErr := p.API.CreatePost(reply); appErr != nil {
+			return appErr
+		}
+	}
+
+	return nil
+}
+
+func securityHubSeverityColor(label string) string {
+	switch label {
+	case "CRITICAL", "HIGH":
+		return colorRed
+	case "MEDIUM":
+		return colorOrange
+	case "LOW":
+		return colorBlue
+	default:
+		return ""
+	}
+}
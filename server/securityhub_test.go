@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func securityHubFindingsMessage(severities ...string) string {
+	var findings []string
+	for i, severity := range severities {
+		findings = append(findings, fmt.Sprintf(`{
+			"SchemaVersion": "2018-10-08",
+			"Id": "finding-%d",
+			"ProductArn": "arn:aws:securityhub:us-east-1::product/aws/securityhub",
+			"ProductName": "Security Hub",
+			"AwsAccountId": "123456789012",
+			"Region": "us-east-1",
+			"Title": "S3 general purpose buckets should block public access",
+			"Severity": {"Label": "%s"},
+			"Compliance": {"Status": "FAILED"},
+			"Workflow": {"Status": "NEW"},
+			"Resources": [{"Type": "AwsS3Bucket", "Id": "arn:aws:s3:::my-bucket", "Region": "us-east-1"}]
+		}`, i, severity))
+	}
+
+	return `{
+		"version": "0",
+		"id": "8e5622f9-d81c-4d81-612a-9319e7ee2506",
+		"detail-type": "Security Hub Findings - Imported",
+		"source": "aws.securityhub",
+		"account": "123456789012",
+		"region": "us-east-1",
+		"detail": {"findings": [` + strings.Join(findings, ",") + `]}
+	}`
+}
+
+func TestSecurityHubParserRender(t *testing.T) {
+	p := &Plugin{}
+	parser := &securityHubParser{p: p}
+
+	notification := &SNSNotification{Message: securityHubFindingsMessage("HIGH", "LOW")}
+	require.True(t, parser.Detect(notification))
+	assert.False(t, parser.Detect(&SNSNotification{Message: guardDutyFindingMessage}))
+
+	event, err := parser.Parse(notification)
+	require.NoError(t, err)
+
+	attachments := parser.Render(notification, event)
+	require.Len(t, attachments, 2)
+	assert.Equal(t, "S3 general purpose buckets should block public access", attachments[0].Title)
+	assert.Equal(t, colorRed, attachments[0].Color)
+	assert.Equal(t, colorBlue, attachments[1].Color)
+
+	values := map[string]string{}
+	for _, field := range attachments[0].Fields {
+		values[field.Title] = field.Value.(string)
+	}
+	assert.Equal(t, "HIGH", values["Severity"])
+	assert.Equal(t, "FAILED", values["Compliance Status"])
+	assert.Equal(t, "Security Hub", values["Product"])
+	assert.Equal(t, "AwsS3Bucket [arn:aws:s3:::my-bucket](https://console.aws.amazon.com/go/view?arn=arn%3Aaws%3As3%3A%3A%3Amy-bucket)", values["Resources"])
+}
+
+func TestSecurityHubParserPost(t *testing.T) {
+	channel := &TeamChannel{ChannelID: "channelId1"}
+
+	for name, test := range map[string]struct {
+		Severities      []string
+		ExpectedPosts   []int
+		ExpectedSummary string
+	}{
+		"Small batch in a single post": {
+			Severities:    []string{"HIGH", "LOW"},
+			ExpectedPosts: []int{2},
+		},
+		"Large batch in a thread": {
+			Severities:      []string{"LOW", "CRITICAL", "LOW", "MEDIUM", "LOW", "LOW", "HIGH"},
+			ExpectedPosts:   []int{1, 5, 2},
+			ExpectedSummary: "7 Security Hub findings imported",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			api := &plugintest.API{}
+			p := &Plugin{BotUserID: "botId"}
+			p.SetAPI(api)
+			parser := &securityHubParser{p: p}
+
+			var posts []*model.Post
+			api.On("CreatePost", mock.AnythingOfType("*model.Post")).Return(func(post *model.Post) (*model.Post, *model.AppError) {
+				post.Id = fmt.Sprintf("postId%d", len(posts))
+				posts = append(posts, post)
+				return post, nil
+			})
+
+			notification := &SNSNotification{Message: securityHubFindingsMessage(test.Severities...)}
+			event, err := parser.Parse(notification)
+			require.NoError(t, err)
+			parser.Post(event, parser.Render(notification, event), []*TeamChannel{channel})
+
+			require.Len(t, posts, len(test.ExpectedPosts))
+			for i, post := range posts {
+				assert.Len(t, post.Attachments(), test.ExpectedPosts[i])
+				if i > 0 {
+					assert.Equal(t, "postId0", post.RootId)
+				}
+			}
+
+			if test.ExpectedSummary != "" {
+				summary := posts[0].Attachments()[0]
+				assert.Equal(t, test.ExpectedSummary, summary.Title)
+				assert.Equal(t, colorRed, summary.Color)
+				require.Len(t, summary.Fields, 4)
+				assert.Equal(t, "CRITICAL", summary.Fields[0].Title)
+				assert.Equal(t, "4", summary.Fields[3].Value)
+			}
+		})
+	}
+}
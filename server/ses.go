@@ -0,0 +1,365 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
+	"github.com/pkg/errors"
+)
+
+const (
+	sesSummaryPrefix = "sesSummary_"
+	sesSummaryJobKey = "ses_summary"
+	// sesSummaryJobInterval is how often the job checks for summaries that are due.
+	sesSummaryJobInterval = time.Minute
+	// maxSESRecipients caps the number of recipients listed on a post.
+	maxSESRecipients = 10
+
+	sesBounce    = "Bounce"
+	sesComplaint = "Complaint"
+	sesDelivery  = "Delivery"
+)
+
+// SESSummary counts the SES notifications of a channel since the first one of the period.
+type SESSummary struct {
+	Since int64
+	// Counts maps a notification type to its count, and bounces to their "type/subtype" as well.
+	Counts map[string]int
+}
+
+// sesParser handles the bounce, complaint and delivery notifications of Amazon SES.
+type sesParser struct {
+	p *Plugin
+}
+
+func (s *sesParser) Name() string  { return "ses" }
+func (s *sesParser) Priority() int { return 410 }
+
+func (s *sesParser) Detect(notification *SNSNotification) bool {
+	isSESNotification, _ := s.p.isSESNotification(notification.Message)
+	return isSESNotification
+}
+
+func (s *sesParser) Parse(notification *SNSNotification) (interface{}, error) {
+	isSESNotification, messageNotification := s.p.isSESNotification(notification.Message)
+	if !isSESNotification {
+		return nil, errors.New("message is not an SES notification")
+	}
+	return messageNotification, nil
+}
+
+func (s *sesParser) Render(notification *SNSNotification, event interface{}) []*model.SlackAttachment {
+	attachment := s.p.createSNSSesAttachment(event.(SNSSesNotification))
+	return []*model.SlackAttachment{&attachment}
+}
+
+// Post counts the notification towards the periodic summary of each channel when the summary
+// mode is on, and posts it otherwise.
+func (s *sesParser) Post(event interface{}, attachments []*model.SlackAttachment, channels []*TeamChannel) {
+	if s.p.getConfiguration().SESSummaryMinutes <= 0 {
+		s.p.sendPostNotification(attachments, channels)
+		return
+	}
+
+	messageNotification := event.(SNSSesNotification)
+	for _, channel := range channels {
+		if err := s.p.countSESNotification(channel.ChannelID, messageNotification); err != nil {
+			s.p.API.LogError("AWSSNS unable to count the SES notification", "channel_id", channel.ChannelID, "err", err.Error())
+		}
+	}
+}
+
+// sesType returns the type of the notification, Bounce, Complaint or Delivery.
+func (n SNSSesNotification) sesType() string {
+	if n.NotificationType != "" {
+		return n.NotificationType
+	}
+	return n.EventType
+}
+
+func (p *Plugin) isSESNotification(message string) (bool, SNSSesNotification) {
+	var messageNotification SNSSesNotification
+	if err := json.Unmarshal([]byte(message), &messageNotification); err != nil {
+		p.API.LogDebug(
+			"AWSSNS HandleNotification Decode Error on SES message notification",
+			"err", err.Error(),
+			"message", message)
+		return false, messageNotification
+	}
+
+	switch messageNotification.sesType() {
+	case sesBounce, sesComplaint, sesDelivery:
+		return len(messageNotification.Mail.MessageID) > 0, messageNotification
+	default:
+		return false, messageNotification
+	}
+}
+
+func (p *Plugin) createSNSSesAttachment(messageNotification SNSSesNotification) model.SlackAttachment {
+	p.API.LogDebug("AWSSNS HandleNotification SES Notification", "TYPE", messageNotification.sesType())
+
+	var fields []*model.SlackAttachmentField
+	var recipients []string
+	var color string
+
+	switch {
+	case messageNotification.Bounce != nil:
+		bounce := messageNotification.Bounce
+		fields = addFields(fields, "Bounce Type", bounce.BounceType, true)
+		fields = addFields(fields, "Bounce Subtype", bounce.BounceSubType, true)
+		var diagnosticCodes []string
+		for _, recipient := range bounce.BouncedRecipients {
+			recipients = append(recipients, recipient.EmailAddress)
+			if recipient.DiagnosticCode != "" {
+				diagnosticCodes = append(diagnosticCodes, recipient.DiagnosticCode)
+			}
+		}
+		if len(diagnosticCodes) > 0 {
+			fields = addFields(fields, "Diagnostic Code", strings.Join(diagnosticCodes, "\n"), false)
+		}
+		color = colorOrange
+		if bounce.BounceType == "Permanent" {
+			color = colorRed
+		}
+	case messageNotification.Complaint != nil:
+		complaint := messageNotification.Complaint
+		if complaint.ComplaintFeedbackType != "" {
+			fields = addFields(fields, "Feedback Type", complaint.ComplaintFeedbackType, true)
+		}
+		for _, recipient := range complaint.ComplainedRecipients {
+			recipients = append(recipients, recipient.EmailAddress)
+		}
+		color = colorRed
+	case messageNotification.Delivery != nil:
+		recipients = messageNotification.Delivery.Recipients
+		if messageNotification.Delivery.SMTPResponse != "" {
+			fields = addFields(fields, "SMTP Response", messageNotification.Delivery.SMTPResponse, false)
+		}
+		color = colorGreen
+	}
+
+	if len(recipients) > maxSESRecipients {
+		recipients = append(recipients[:maxSESRecipients:maxSESRecipients], fmt.Sprintf("and %d more", len(recipients)-maxSESRecipients))
+	}
+	fields = addFields(fields, "Recipients", strings.Join(recipients, "\n"), false)
+	fields = addFields(fields, "Source", messageNotification.Mail.Source, true)
+	fields = addFields(fields, "Message ID", messageNotification.Mail.MessageID, true)
+
+	return model.SlackAttachment{
+		Title:  fmt.Sprintf("Amazon SES %s", messageNotification.sesType()),
+		Fields: fields,
+		Color:  color,
+		Footer: messageNotification.Mail.SourceArn,
+	}
+}
+
+// countSESNotification adds the notification to the summary of the channel. The summary is
+// updated atomically, so concurrent notifications are all counted.
+func (p *Plugin) countSESNotification(channelID string, messageNotification SNSSesNotification) error {
+	return p.client.KV.SetAtomicWithRetries(sesSummaryPrefix+channelID, func(oldValue []byte) (interface{}, error) {
+		summary := &SESSummary{Since: model.GetMillis(), Counts: map[string]int{}}
+		if oldValue != nil {
+			if err := json.Unmarshal(oldValue, summary); err != nil {
+				return nil, errors.Wrap(err, "failed to unmarshal SES summary")
+			}
+		}
+
+		summary.Counts[messageNotification.sesType()]++
+		if bounce := messageNotification.Bounce; bounce != nil {
+			summary.Counts[sesBounce+": "+bounce.BounceType+"/"+bounce.BounceSubType]++
+		}
+		return summary, nil
+	})
+}
+
+// postSESSummaries posts and resets the summary of every channel whose period is over.
+func (p *Plugin) postSESSummaries() {
+	period := time.Duration(p.getConfiguration().SESSummaryMinutes) * time.Minute
+	if period <= 0 {
+		return
+	}
+
+	for _, channel := range p.Channels {
+		if err := p.postSESSummary(channel, period); err != nil {
+			p.API.LogError("AWSSNS unable to post the SES summary", "channel_id", channel.ChannelID, "err", err.Error())
+		}
+	}
+}
+
+// postSESSummary posts the summary of the channel when its period is over, then removes the
+// posted counts. Notifications counted while the summary was posted are kept for the next one,
+// and nothing is removed when posting fails.
+func (p *Plugin) postSESSummary(channel *TeamChannel, period time.Duration) error {
+	key := sesSummaryPrefix + channel.ChannelID
+	posted, appErr := p.API.KVGet(key)
+	if appErr != nil {
+		return appErr
+	}
+	if posted == nil {
+		return nil
+	}
+
+	var summary SESSummary
+	if err := json.Unmarshal(posted, &summary); err != nil {
+		return errors.Wrap(err, "failed to unmarshal SES summary")
+	}
+	if time.Since(time.UnixMilli(summary.Since)) < period {
+		return nil
+	}
+
+	post := &model.Post{
+		ChannelId: channel.ChannelID,
+		UserId:    p.BotUserID,
+	}
+	model.ParseSlackAttachment(post, []*model.SlackAttachment{createSESSummaryAttachment(&summary)})
+	if _, appErr = p.API.CreatePost(post); appErr != nil {
+		return appErr
+	}
+	postedAt := model.GetMillis()
+
+	deleted, appErr := p.API.KVCompareAndDelete(key, posted)
+	if appErr != nil {
+		return appErr
+	}
+	if deleted {
+		return nil
+	}
+
+	return p.client.KV.SetAtomicWithRetries(key, func(oldValue []byte) (interface{}, error) {
+		if oldValue == nil {
+			return nil, nil
+		}
+		current := &SESSummary{}
+		if err := json.Unmarshal(oldValue, current); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal SES summary")
+		}
+		for countKey, count := range summary.Counts {
+			current.Counts[countKey] -= count
+			if current.Counts[countKey] <= 0 {
+				delete(current.Counts, countKey)
+			}
+		}
+		if len(current.Counts) == 0 {
+			return nil, nil
+		}
+		current.Since = postedAt
+		return current, nil
+	})
+}
+
+// flushSESSummaries posts the counts left when the summary mode is turned off, whatever their
+// period. No notification is counted anymore at that point, so each summary is removed before it
+// is posted, which lets a single node of a cluster post it.
+func (p *Plugin) flushSESSummaries() {
+	for _, channel := range p.Channels {
+		if err := p.flushSESSummary(channel); err != nil {
+			p.API.LogError("AWSSNS unable to post the remaining SES summary", "channel_id", channel.ChannelID, "err", err.Error())
+		}
+	}
+}
+
+func (p *Plugin) flushSESSummary(channel *TeamChannel) error {
+	key := sesSummaryPrefix + channel.ChannelID
+	pending, appErr := p.API.KVGet(key)
+	if appErr != nil {
+		return appErr
+	}
+	if pending == nil {
+		return nil
+	}
+
+	var summary SESSummary
+	if err := json.Unmarshal(pending, &summary); err != nil {
+		return errors.Wrap(err, "failed to unmarshal SES summary")
+	}
+
+	deleted, appErr := p.API.KVCompareAndDelete(key, pending)
+	if appErr != nil {
+		return appErr
+	}
+	if !deleted {
+		return nil
+	}
+
+	post := &model.Post{
+		ChannelId: channel.ChannelID,
+		UserId:    p.BotUserID,
+	}
+	model.ParseSlackAttachment(post, []*model.SlackAttachment{createSESSummaryAttachment(&summary)})
+	if _, appErr = p.API.CreatePost(post); appErr != nil {
+		return appErr
+	}
+	return nil
+}
+
+func createSESSummaryAttachment(summary *SESSummary) *model.SlackAttachment {
+	var fields []*model.SlackAttachmentField
+	fields = addFields(fields, "Bounces", strconv.Itoa(summary.Counts[sesBounce]), true)
+	fields = addFields(fields, "Complaints", strconv.Itoa(summary.Counts[sesComplaint]), true)
+	fields = addFields(fields, "Deliveries", strconv.Itoa(summary.Counts[sesDelivery]), true)
+
+	var bounceTypes []string
+	for key, count := range summary.Counts {
+		if bounceType := strings.TrimPrefix(key, sesBounce+": "); bounceType != key {
+			bounceTypes = append(bounceTypes, fmt.Sprintf("%s: %d", bounceType, count))
+		}
+	}
+	sort.Strings(bounceTypes)
+	if len(bounceTypes) > 0 {
+		fields = addFields(fields, "Bounces by Type", strings.Join(bounceTypes, "\n"), false)
+	}
+
+	color := colorGreen
+	switch {
+	case summary.Counts[sesComplaint] > 0:
+		color = colorRed
+	case summary.Counts[sesBounce] > 0:
+		color = colorOrange
+	}
+
+	return &model.SlackAttachment{
+		Title:  "Amazon SES summary",
+		Text:   fmt.Sprintf("Notifications received since %s.", time.UnixMilli(summary.Since).UTC().Format(time.RFC1123)),
+		Fields: fields,
+		Color:  color,
+	}
+}
+
+// ensureSESSummaryJob starts the job posting the SES summaries when the summary mode is turned
+// on, and stops it and posts the remaining counts when it is turned off. The job runs on a single
+// node of a cluster.
+func (p *Plugin) ensureSESSummaryJob() {
+	if p.updateSESSummaryJob() {
+		p.flushSESSummaries()
+	}
+}
+
+// updateSESSummaryJob schedules or stops the job, and returns whether it was stopped.
+func (p *Plugin) updateSESSummaryJob() bool {
+	p.sesSummaryJobLock.Lock()
+	defer p.sesSummaryJobLock.Unlock()
+
+	enabled := p.getConfiguration().SESSummaryMinutes > 0
+	switch {
+	case enabled && p.sesSummaryJob == nil:
+		job, err := cluster.Schedule(p.API, sesSummaryJobKey, cluster.MakeWaitForInterval(sesSummaryJobInterval), p.postSESSummaries)
+		if err != nil {
+			p.API.LogError("AWSSNS unable to schedule the SES summary job", "err", err.Error())
+			return false
+		}
+		p.sesSummaryJob = job
+	case !enabled && p.sesSummaryJob != nil:
+		if err := p.sesSummaryJob.Close(); err != nil {
+			p.API.LogWarn("AWSSNS unable to stop the SES summary job", "err", err.Error())
+		}
+		p.sesSummaryJob = nil
+		return true
+	}
+	return false
+}
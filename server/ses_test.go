@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sesBounceMessage = `{
+	"notificationType": "Bounce",
+	"bounce": {
+		"bounceType": "Permanent",
+		"bounceSubType": "General",
+		"bouncedRecipients": [{"emailAddress": "jane@example.com", "action": "failed", "status": "5.1.1", "diagnosticCode": "smtp; 550 5.1.1 user unknown"}],
+		"timestamp": "2016-01-27T14:59:38.237Z",
+		"feedbackId": "00000137860315fd-869464a4-8680-4114-98d3-716fe35851f9-000000"
+	},
+	"mail": {
+		"timestamp": "2016-01-27T14:59:38.237Z",
+		"messageId": "00000137860315fd-34208509-5b74-41f3-95c5-22c1edc3c924-000000",
+		"source": "john@example.com",
+		"sourceArn": "arn:aws:ses:us-east-1:888888888888:identity/example.com",
+		"destination": ["jane@example.com"]
+	}
+}`
+
+func TestSESParserRender(t *testing.T) {
+	api := &plugintest.API{}
+	api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything).Maybe()
+	p := &Plugin{}
+	p.SetAPI(api)
+	parser := &sesParser{p: p}
+
+	notification := &SNSNotification{Message: sesBounceMessage}
+	require.True(t, parser.Detect(notification))
+	assert.True(t, parser.Detect(&SNSNotification{Message: `{"eventType": "Complaint", "complaint": {"complainedRecipients": [{"emailAddress": "jane@example.com"}]}, "mail": {"messageId": "1"}}`}))
+	assert.False(t, parser.Detect(&SNSNotification{Message: `{"notificationType": "AmazonSnsSubscriptionSucceeded", "mail": {"messageId": "1"}}`}))
+
+	event, err := parser.Parse(notification)
+	require.NoError(t, err)
+	attachments := parser.Render(notification, event)
+	require.Len(t, attachments, 1)
+
+	assert.Equal(t, "Amazon SES Bounce", attachments[0].Title)
+	assert.Equal(t, colorRed, attachments[0].Color)
+	values := renderFields(attachments[0])
+	assert.Equal(t, "Permanent", values["Bounce Type"])
+	assert.Equal(t, "General", values["Bounce Subtype"])
+	assert.Equal(t, "smtp; 550 5.1.1 user unknown", values["Diagnostic Code"])
+	assert.Equal(t, "jane@example.com", values["Recipients"])
+	assert.Equal(t, "00000137860315fd-34208509-5b74-41f3-95c5-22c1edc3c924-000000", values["Message ID"])
+}
+
+func TestSESSummary(t *testing.T) {
+	channel := &TeamChannel{ChannelID: "channelId1"}
+	key := sesSummaryPrefix + channel.ChannelID
+
+	setupPlugin := func(api *plugintest.API) *Plugin {
+		p := &Plugin{BotUserID: "botId", Channels: []*TeamChannel{channel}}
+		p.SetAPI(api)
+		p.client = pluginapi.NewClient(api, &plugintest.Driver{})
+		p.setConfiguration(&configuration{SESSummaryMinutes: 60})
+		return p
+	}
+
+	t.Run("Notifications are counted instead of posted", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything).Maybe()
+		p := setupPlugin(api)
+		parser := &sesParser{p: p}
+
+		existing, err := json.Marshal(&SESSummary{Since: 1000, Counts: map[string]int{sesDelivery: 4}})
+		require.NoError(t, err)
+		api.On("KVGet", key).Return(existing, nil)
+		api.On("KVSetWithOptions", key, mock.Anything, model.PluginKVSetOptions{Atomic: true, OldValue: existing}).Return(func(key string, value []byte, options model.PluginKVSetOptions) (bool, *model.AppError) {
+			var summary SESSummary
+			require.NoError(t, json.Unmarshal(value, &summary))
+			assert.Equal(t, int64(1000), summary.Since)
+			assert.Equal(t, map[string]int{sesDelivery: 4, sesBounce: 1, "Bounce: Permanent/General": 1}, summary.Counts)
+			return true, nil
+		})
+
+		notification := &SNSNotification{Message: sesBounceMessage}
+		event, err := parser.Parse(notification)
+		require.NoError(t, err)
+		parser.Post(event, parser.Render(notification, event), []*TeamChannel{channel})
+
+		api.AssertExpectations(t)
+		api.AssertNotCalled(t, "CreatePost", mock.Anything)
+	})
+
+	t.Run("Summary is not due yet", func(t *testing.T) {
+		api := &plugintest.API{}
+		p := setupPlugin(api)
+
+		existing, err := json.Marshal(&SESSummary{Since: model.GetMillis(), Counts: map[string]int{sesBounce: 2}})
+		require.NoError(t, err)
+		api.On("KVGet", key).Return(existing, nil)
+
+		p.postSESSummaries()
+
+		api.AssertExpectations(t)
+		api.AssertNotCalled(t, "KVSetWithOptions", mock.Anything, mock.Anything, mock.Anything)
+		api.AssertNotCalled(t, "CreatePost", mock.Anything)
+	})
+
+	t.Run("Summary is posted and reset", func(t *testing.T) {
+		api := &plugintest.API{}
+		p := setupPlugin(api)
+
+		since := time.Now().Add(-2 * time.Hour).UnixMilli()
+		existing, err := json.Marshal(&SESSummary{Since: since, Counts: map[string]int{sesBounce: 2, "Bounce: Permanent/General": 1, "Bounce: Transient/MailboxFull": 1, sesDelivery: 10}})
+		require.NoError(t, err)
+		api.On("KVGet", key).Return(existing, nil)
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			attachment := post.Attachments()[0]
+			values := renderFields(attachment)
+			return post.ChannelId == channel.ChannelID &&
+				attachment.Color == colorOrange &&
+				values["Bounces"] == "2" &&
+				values["Complaints"] == "0" &&
+				values["Deliveries"] == "10" &&
+				values["Bounces by Type"] == "Permanent/General: 1\nTransient/MailboxFull: 1"
+		})).Return(&model.Post{}, nil)
+		api.On("KVCompareAndDelete", key, existing).Return(true, nil)
+
+		p.postSESSummaries()
+
+		api.AssertExpectations(t)
+	})
+
+	t.Run("Summary is kept when posting fails", func(t *testing.T) {
+		api := &plugintest.API{}
+		p := setupPlugin(api)
+
+		existing, err := json.Marshal(&SESSummary{Since: time.Now().Add(-2 * time.Hour).UnixMilli(), Counts: map[string]int{sesDelivery: 10}})
+		require.NoError(t, err)
+		api.On("KVGet", key).Return(existing, nil)
+		api.On("CreatePost", mock.Anything).Return(nil, &model.AppError{Message: "failed"})
+		api.On("LogError", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+
+		p.postSESSummaries()
+
+		api.AssertExpectations(t)
+		api.AssertNotCalled(t, "KVCompareAndDelete", mock.Anything, mock.Anything)
+		api.AssertNotCalled(t, "KVSetWithOptions", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Notifications counted while posting are kept", func(t *testing.T) {
+		api := &plugintest.API{}
+		p := setupPlugin(api)
+
+		existing, err := json.Marshal(&SESSummary{Since: time.Now().Add(-2 * time.Hour).UnixMilli(), Counts: map[string]int{sesDelivery: 10}})
+		require.NoError(t, err)
+		counted, err := json.Marshal(&SESSummary{Since: time.Now().Add(-2 * time.Hour).UnixMilli(), Counts: map[string]int{sesDelivery: 11, sesComplaint: 1}})
+		require.NoError(t, err)
+		api.On("KVGet", key).Return(existing, nil).Once()
+		api.On("CreatePost", mock.Anything).Return(&model.Post{}, nil)
+		api.On("KVCompareAndDelete", key, existing).Return(false, nil)
+		api.On("KVGet", key).Return(counted, nil).Once()
+		api.On("KVSetWithOptions", key, mock.MatchedBy(func(b []byte) bool {
+			var kept SESSummary
+			return json.Unmarshal(b, &kept) == nil &&
+				len(kept.Counts) == 2 && kept.Counts[sesDelivery] == 1 && kept.Counts[sesComplaint] == 1 &&
+				time.Since(time.UnixMilli(kept.Since)) < time.Minute
+		}), model.PluginKVSetOptions{Atomic: true, OldValue: counted}).Return(true, nil)
+
+		p.postSESSummaries()
+
+		api.AssertExpectations(t)
+	})
+}
+
+func TestFlushSESSummaries(t *testing.T) {
+	channel := &TeamChannel{TeamID: "teamId", ChannelID: "channelId"}
+	key := sesSummaryPrefix + channel.ChannelID
+	setupPlugin := func(api *plugintest.API) *Plugin {
+		p := &Plugin{BotUserID: "botId", Channels: []*TeamChannel{channel}}
+		p.setConfiguration(&configuration{})
+		p.SetAPI(api)
+		return p
+	}
+
+	t.Run("Remaining counts are posted", func(t *testing.T) {
+		api := &plugintest.API{}
+		p := setupPlugin(api)
+
+		pending, err := json.Marshal(&SESSummary{Since: model.GetMillis(), Counts: map[string]int{sesComplaint: 1}})
+		require.NoError(t, err)
+		api.On("KVGet", key).Return(pending, nil)
+		api.On("KVCompareAndDelete", key, pending).Return(true, nil)
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			attachment := post.Attachments()[0]
+			return post.ChannelId == channel.ChannelID && attachment.Color == colorRed && renderFields(attachment)["Complaints"] == "1"
+		})).Return(&model.Post{}, nil)
+
+		p.flushSESSummaries()
+
+		api.AssertExpectations(t)
+	})
+
+	t.Run("Counts posted by another node are not posted again", func(t *testing.T) {
+		api := &plugintest.API{}
+		p := setupPlugin(api)
+
+		pending, err := json.Marshal(&SESSummary{Since: model.GetMillis(), Counts: map[string]int{sesDelivery: 3}})
+		require.NoError(t, err)
+		api.On("KVGet", key).Return(pending, nil)
+		api.On("KVCompareAndDelete", key, pending).Return(false, nil)
+
+		p.flushSESSummaries()
+
+		api.AssertExpectations(t)
+		api.AssertNotCalled(t, "CreatePost", mock.Anything)
+	})
+
+	t.Run("Nothing is posted without remaining counts", func(t *testing.T) {
+		api := &plugintest.API{}
+		p := setupPlugin(api)
+
+		api.On("KVGet", key).Return(nil, nil)
+
+		p.flushSESSummaries()
+
+		api.AssertExpectations(t)
+		api.AssertNotCalled(t, "CreatePost", mock.Anything)
+	})
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	signatureVersionSHA1   = "1"
+	signatureVersionSHA256 = "2"
+
+	// maxSNSMessageSize bounds the request bodies read from AWS SNS. SNS messages are limited to
+	// 256KB, the rest leaves room for the JSON envelope and escaping.
+	maxSNSMessageSize = 1024 * 1024
+	// maxSigningCertSize bounds the size of a downloaded signing certificate.
+	maxSigningCertSize = 64 * 1024
+
+	snsHTTPTimeout = 10 * time.Second
+)
+
+// snsEnvelope holds the fields of an AWS SNS message exactly as they were signed by AWS. The
+// values are kept as raw strings, Timestamp included, as the canonical string has to match the
+// payload byte for byte.
+type snsEnvelope struct {
+	Type             string  `json:"Type"`
+	MessageID        string  `json:"MessageId"`
+	Token            string  `json:"Token"`
+	TopicArn         string  `json:"TopicArn"`
+	Subject          *string `json:"Subject"`
+	Message          string  `json:"Message"`
+	SubscribeURL     string  `json:"SubscribeURL"`
+	Timestamp        string  `json:"Timestamp"`
+	SignatureVersion string  `json:"SignatureVersion"`
+	Signature        string  `json:"Signature"`
+	SigningCertURL   string  `json:"SigningCertURL"`
+	UnsubscribeURL   string  `json:"UnsubscribeURL"`
+}
+
+// canonicalString builds the string AWS signs for the message type, as documented in
+// https://docs.aws.amazon.com/sns/latest/dg/sns-verify-signature-of-message.html
+func (e *snsEnvelope) canonicalString() (string, error) {
+	var fields [][2]string
+	switch e.Type {
+	case "Notification":
+		fields = append(fields, [2]string{"Message", e.Message}, [2]string{"MessageId", e.MessageID})
+		if e.Subject != nil {
+			fields = append(fields, [2]string{"Subject", *e.Subject})
+		}
+		fields = append(fields,
+			[2]string{"Timestamp", e.Timestamp},
+			[2]string{"TopicArn", e.TopicArn},
+			[2]string{"Type", e.Type},
+		)
+	case "SubscriptionConfirmation", "UnsubscribeConfirmation":
+		fields = [][2]string{
+			{"Message", e.Message},
+			{"MessageId", e.MessageID},
+			{"SubscribeURL", e.SubscribeURL},
+			{"Timestamp", e.Timestamp},
+			{"Token", e.Token},
+			{"TopicArn", e.TopicArn},
+			{"Type", e.Type},
+		}
+	default:
+		return "", fmt.Errorf("unsupported message type %q", e.Type)
+	}
+
+	var b strings.Builder
+	for _, field := range fields {
+		b.WriteString(field[0])
+		b.WriteString("\n")
+		b.WriteString(field[1])
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
+
+// verifySNSMessage checks the signature of a message against the certificate referenced by
+// its SigningCertURL.
+func (p *Plugin) verifySNSMessage(envelope *snsEnvelope) error {
+	var hash crypto.Hash
+	switch envelope.SignatureVersion {
+	case signatureVersionSHA1:
+		hash = crypto.SHA1
+	case signatureVersionSHA256:
+		hash = crypto.SHA256
+	default:
+		return fmt.Errorf("unsupported signature version %q", envelope.SignatureVersion)
+	}
+
+	if err := validateSigningCertURL(envelope.SigningCertURL); err != nil {
+		return err
+	}
+
+	// the certificate has to come from the SNS endpoint of the region of the topic
+	topicArn, err := parseTopicArn(envelope.TopicArn)
+	if err != nil {
+		return err
+	}
+	if err := validateSNSURLRegion(envelope.SigningCertURL, topicArn); err != nil {
+		return err
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(envelope.Signature)
+	if err != nil {
+		return errors.Wrap(err, "failed to decode signature")
+	}
+
+	canonical, err := envelope.canonicalString()
+	if err != nil {
+		return err
+	}
+
+	cert, err := p.signingCerts.get(envelope.SigningCertURL)
+	if err != nil {
+		return err
+	}
+
+	return verifySignature(cert, hash, []byte(canonical), signature)
+}
+
+func verifySignature(cert *x509.Certificate, hash crypto.Hash, message, signature []byte) error {
+	publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("signing certificate does not hold an RSA public key")
+	}
+
+	var digest []byte
+	switch hash {
+	case crypto.SHA1:
+		sum := sha1.Sum(message)
+		digest = sum[:]
+	case crypto.SHA256:
+		sum := sha256.Sum256(message)
+		digest = sum[:]
+	default:
+		return fmt.Errorf("unsupported hash %v", hash)
+	}
+
+	if err := rsa.VerifyPKCS1v15(publicKey, hash, digest, signature); err != nil {
+		return errors.Wrap(err, "signature does not match")
+	}
+	return nil
+}
+
+// certificateCache downloads and keeps the SNS signing certificates until they expire.
+type certificateCache struct {
+	client *http.Client
+
+	lock  sync.RWMutex
+	certs map[string]*x509.Certificate
+}
+
+func newCertificateCache(client *http.Client) *certificateCache {
+	return &certificateCache{
+		client: client,
+		certs:  make(map[string]*x509.Certificate),
+	}
+}
+
+func (c *certificateCache) get(certURL string) (*x509.Certificate, error) {
+	c.lock.RLock()
+	cert, ok := c.certs[certURL]
+	c.lock.RUnlock()
+	if ok && time.Now().Before(cert.NotAfter) {
+		return cert, nil
+	}
+
+	cert, err := c.fetch(certURL)
+	if err != nil {
+		return nil, err
+	}
+
+	c.lock.Lock()
+	c.certs[certURL] = cert
+	c.lock.Unlock()
+
+	return cert, nil
+}
+
+func (c *certificateCache) fetch(certURL string) (*x509.Certificate, error) {
+	resp, err := c.client.Get(certURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to download signing certificate")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download signing certificate: status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSigningCertSize))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read signing certificate")
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("signing certificate is not a PEM encoded certificate")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse signing certificate")
+	}
+
+	now := time.Now()
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return nil, errors.New("signing certificate is not valid at this time")
+	}
+
+	return cert, nil
+}
+
+// newSNSHTTPClient returns the client used to talk to AWS SNS. Redirects are not followed so a
+// request never ends up on a host other than the one that was checked.
+func newSNSHTTPClient() *http.Client {
+	return &http.Client{
+		Timeout: snsHTTPTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSigningCertURL = "https://sns.us-east-1.amazonaws.com/SimpleNotificationService-test.pem"
+
+func newTestSigningCert(t *testing.T) (*rsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sns.amazonaws.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return key, cert
+}
+
+func signTestEnvelope(t *testing.T, key *rsa.PrivateKey, envelope *snsEnvelope) {
+	t.Helper()
+
+	canonical, err := envelope.canonicalString()
+	require.NoError(t, err)
+
+	var hash crypto.Hash
+	var digest []byte
+	if envelope.SignatureVersion == signatureVersionSHA1 {
+		sum := sha1.Sum([]byte(canonical))
+		hash, digest = crypto.SHA1, sum[:]
+	} else {
+		sum := sha256.Sum256([]byte(canonical))
+		hash, digest = crypto.SHA256, sum[:]
+	}
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, hash, digest)
+	require.NoError(t, err)
+	envelope.Signature = base64.StdEncoding.EncodeToString(signature)
+}
+
+func newTestNotification(signatureVersion string) *snsEnvelope {
+	subject := "ALARM: \"cpu\" in US East (N. Virginia)"
+	return &snsEnvelope{
+		Type:             "Notification",
+		MessageID:        "da41e39f-ea4d-435a-b922-c6aae3915ebe",
+		TopicArn:         "arn:aws:sns:us-east-1:123456789012:alarms",
+		Subject:          &subject,
+		Message:          "{\"AlarmName\":\"cpu\"}",
+		Timestamp:        "2012-04-25T21:49:25.719Z",
+		SignatureVersion: signatureVersion,
+		SigningCertURL:   testSigningCertURL,
+	}
+}
+
+func TestVerifySNSMessage(t *testing.T) {
+	key, cert := newTestSigningCert(t)
+
+	for name, test := range map[string]struct {
+		Envelope    func() *snsEnvelope
+		ShouldError bool
+	}{
+		"Notification signed with SignatureVersion 1": {
+			Envelope: func() *snsEnvelope {
+				envelope := newTestNotification(signatureVersionSHA1)
+				signTestEnvelope(t, key, envelope)
+				return envelope
+			},
+		},
+		"Notification signed with SignatureVersion 2": {
+			Envelope: func() *snsEnvelope {
+				envelope := newTestNotification(signatureVersionSHA256)
+				signTestEnvelope(t, key, envelope)
+				return envelope
+			},
+		},
+		"Notification without subject": {
+			Envelope: func() *snsEnvelope {
+				envelope := newTestNotification(signatureVersionSHA256)
+				envelope.Subject = nil
+				signTestEnvelope(t, key, envelope)
+				return envelope
+			},
+		},
+		"Subscription confirmation": {
+			Envelope: func() *snsEnvelope {
+				envelope := &snsEnvelope{
+					Type:             "SubscriptionConfirmation",
+					MessageID:        "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
+					Token:            "2336412f37",
+					TopicArn:         "arn:aws:sns:us-east-1:123456789012:alarms",
+					Message:          "You have chosen to subscribe to the topic",
+					SubscribeURL:     "https://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription",
+					Timestamp:        "2012-04-26T20:45:04.751Z",
+					SignatureVersion: signatureVersionSHA1,
+					SigningCertURL:   testSigningCertURL,
+				}
+				signTestEnvelope(t, key, envelope)
+				return envelope
+			},
+		},
+		"Signing certificate of another region": {
+			Envelope: func() *snsEnvelope {
+				envelope := newTestNotification(signatureVersionSHA256)
+				envelope.TopicArn = "arn:aws:sns:eu-west-1:123456789012:alarms"
+				signTestEnvelope(t, key, envelope)
+				return envelope
+			},
+			ShouldError: true,
+		},
+		"Invalid topic": {
+			Envelope: func() *snsEnvelope {
+				envelope := newTestNotification(signatureVersionSHA256)
+				envelope.TopicArn = "alarms"
+				signTestEnvelope(t, key, envelope)
+				return envelope
+			},
+			ShouldError: true,
+		},
+		"Tampered message": {
+			Envelope: func() *snsEnvelope {
+				envelope := newTestNotification(signatureVersionSHA256)
+				signTestEnvelope(t, key, envelope)
+				envelope.Message = "{\"AlarmName\":\"forged\"}"
+				return envelope
+			},
+			ShouldError: true,
+		},
+		"Signature version mismatch": {
+			Envelope: func() *snsEnvelope {
+				envelope := newTestNotification(signatureVersionSHA1)
+				signTestEnvelope(t, key, envelope)
+				envelope.SignatureVersion = signatureVersionSHA256
+				return envelope
+			},
+			ShouldError: true,
+		},
+		"Unsupported signature version": {
+			Envelope: func() *snsEnvelope {
+				envelope := newTestNotification("3")
+				envelope.Signature = base64.StdEncoding.EncodeToString([]byte("signature"))
+				return envelope
+			},
+			ShouldError: true,
+		},
+		"Missing signature": {
+			Envelope: func() *snsEnvelope {
+				return newTestNotification(signatureVersionSHA256)
+			},
+			ShouldError: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p := Plugin{signingCerts: newCertificateCache(http.DefaultClient)}
+			p.signingCerts.certs[testSigningCertURL] = cert
+
+			err := p.verifySNSMessage(test.Envelope())
+			if test.ShouldError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCertificateCache(t *testing.T) {
+	_, cert := newTestSigningCert(t)
+
+	requests := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_ = pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}))
+	defer server.Close()
+
+	cache := newCertificateCache(server.Client())
+
+	got, err := cache.get(server.URL + "/cert.pem")
+	require.NoError(t, err)
+	assert.Equal(t, cert.Raw, got.Raw)
+
+	_, err = cache.get(server.URL + "/cert.pem")
+	require.NoError(t, err)
+	assert.Equal(t, 1, requests, "the certificate should be served from the cache")
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -33,33 +34,73 @@ type SNSNotification struct {
 	UnsubscribeURL   string    `json:"UnsubscribeURL,omitempty"`
 }
 
-// SNSMessageNotification holds the CloudWatch Alarm message from AWS
+// SNSMessageNotification holds the CloudWatch Alarm message from AWS. Composite alarms set
+// AlarmRule and TriggeringChildren instead of Trigger
 type SNSMessageNotification struct {
-	AlarmName        string `json:"AlarmName"`
-	AlarmDescription string `json:"AlarmDescription,omitempty"`
-	AWSAccountID     string `json:"AWSAccountId"`
-	NewStateValue    string `json:"NewStateValue"`
-	NewStateReason   string `json:"NewStateReason"`
-	StateChangeTime  string `json:"StateChangeTime"`
-	Region           string `json:"Region"`
-	OldStateValue    string `json:"OldStateValue"`
-	Trigger          struct {
-		MetricName    string `json:"MetricName"`
-		Namespace     string `json:"Namespace"`
-		StatisticType string `json:"StatisticType"`
-		Statistic     string `json:"Statistic"`
-		Unit          string `json:"Unit,omitempty"`
-		Dimensions    []struct {
-			Value string `json:"value"`
-			Name  string `json:"name"`
-		} `json:"Dimensions"`
-		Period                           int     `json:"Period"`
-		EvaluationPeriods                int     `json:"EvaluationPeriods"`
-		ComparisonOperator               string  `json:"ComparisonOperator"`
-		Threshold                        float32 `json:"Threshold"`
-		TreatMissingData                 string  `json:"TreatMissingData"`
-		EvaluateLowSampleCountPercentile string  `json:"EvaluateLowSampleCountPercentile"`
-	} `json:"Trigger"`
+	AlarmName          string                 `json:"AlarmName"`
+	AlarmDescription   string                 `json:"AlarmDescription,omitempty"`
+	AlarmArn           string                 `json:"AlarmArn,omitempty"`
+	AWSAccountID       string                 `json:"AWSAccountId"`
+	NewStateValue      string                 `json:"NewStateValue"`
+	NewStateReason     string                 `json:"NewStateReason"`
+	StateChangeTime    string                 `json:"StateChangeTime"`
+	Region             string                 `json:"Region"`
+	OldStateValue      string                 `json:"OldStateValue"`
+	Trigger            AlarmTrigger           `json:"Trigger"`
+	AlarmRule          string                 `json:"AlarmRule,omitempty"`
+	TriggeringChildren []AlarmTriggeringChild `json:"TriggeringChildren,omitempty"`
+}
+
+// AlarmTrigger holds the metric condition of a CloudWatch alarm. Single metric alarms set the
+// metric fields, metric math and anomaly detection alarms set Metrics instead
+type AlarmTrigger struct {
+	MetricName                       string                 `json:"MetricName"`
+	Namespace                        string                 `json:"Namespace"`
+	StatisticType                    string                 `json:"StatisticType"`
+	Statistic                        string                 `json:"Statistic"`
+	Unit                             string                 `json:"Unit,omitempty"`
+	Dimensions                       []AlarmDimension       `json:"Dimensions"`
+	Period                           int                    `json:"Period"`
+	EvaluationPeriods                int                    `json:"EvaluationPeriods"`
+	ComparisonOperator               string                 `json:"ComparisonOperator"`
+	Threshold                        float32                `json:"Threshold"`
+	ThresholdMetricID                string                 `json:"ThresholdMetricId,omitempty"`
+	TreatMissingData                 string                 `json:"TreatMissingData"`
+	EvaluateLowSampleCountPercentile string                 `json:"EvaluateLowSampleCountPercentile"`
+	Metrics                          []AlarmMetricDataQuery `json:"Metrics,omitempty"`
+}
+
+// AlarmDimension holds a dimension of an alarm metric
+type AlarmDimension struct {
+	Value string `json:"value"`
+	Name  string `json:"name"`
+}
+
+// AlarmMetricDataQuery holds a metric math expression, or a metric when MetricStat is set
+type AlarmMetricDataQuery struct {
+	ID         string `json:"Id"`
+	Expression string `json:"Expression,omitempty"`
+	Label      string `json:"Label,omitempty"`
+	ReturnData bool   `json:"ReturnData"`
+	MetricStat *struct {
+		Metric struct {
+			Dimensions []AlarmDimension `json:"Dimensions"`
+			MetricName string           `json:"MetricName"`
+			Namespace  string           `json:"Namespace"`
+		} `json:"Metric"`
+		Period int    `json:"Period"`
+		Stat   string `json:"Stat"`
+		Unit   string `json:"Unit,omitempty"`
+	} `json:"MetricStat,omitempty"`
+}
+
+// AlarmTriggeringChild holds a child alarm whose state change triggered a composite alarm
+type AlarmTriggeringChild struct {
+	Arn   string `json:"Arn"`
+	State struct {
+		Value     string `json:"Value"`
+		Timestamp string `json:"Timestamp"`
+	} `json:"State"`
 }
 
 type SNSRdsEventNotification struct {
@@ -67,6 +108,7 @@ type SNSRdsEventNotification struct {
 	EventTime      string `json:"Event Time"`
 	IdentifierLink string `json:"Identifier Link"`
 	SourceID       string `json:"Source ID"`
+	SourceARN      string `json:"Source ARN,omitempty"`
 	EventID        string `json:"Event ID"`
 	EventMessage   string `json:"Event Message"`
 }
@@ -86,3 +128,317 @@ type SNSCloudformationEventNotification struct {
 	StackName            string `json:"StackName"`
 	ClientRequestToken   string `json:"ClientRequestToken"`
 }
+
+// EventBridgeEvent holds an Amazon EventBridge event delivered to an SNS topic by an EventBridge rule
+type EventBridgeEvent struct {
+	Version    string          `json:"version"`
+	ID         string          `json:"id"`
+	DetailType string          `json:"detail-type"`
+	Source     string          `json:"source"`
+	Account    string          `json:"account"`
+	Time       string          `json:"time"`
+	Region     string          `json:"region"`
+	Resources  []string        `json:"resources"`
+	Detail     json.RawMessage `json:"detail"`
+
+	// NotificationDetailType is set instead of DetailType by AWS CodeStar Notifications
+	NotificationDetailType string `json:"detailType,omitempty"`
+}
+
+// GuardDutyFinding holds the detail of a "GuardDuty Finding" EventBridge event
+type GuardDutyFinding struct {
+	AccountID   string  `json:"accountId"`
+	Region      string  `json:"region"`
+	Partition   string  `json:"partition"`
+	ID          string  `json:"id"`
+	Arn         string  `json:"arn"`
+	Type        string  `json:"type"`
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	Severity    float64 `json:"severity"`
+	Resource    struct {
+		ResourceType    string `json:"resourceType"`
+		InstanceDetails *struct {
+			InstanceID string `json:"instanceId"`
+		} `json:"instanceDetails,omitempty"`
+		AccessKeyDetails *struct {
+			AccessKeyID string `json:"accessKeyId"`
+			PrincipalID string `json:"principalId"`
+			UserName    string `json:"userName"`
+			UserType    string `json:"userType"`
+		} `json:"accessKeyDetails,omitempty"`
+		S3BucketDetails []struct {
+			Name string `json:"name"`
+			Arn  string `json:"arn"`
+		} `json:"s3BucketDetails,omitempty"`
+	} `json:"resource"`
+	Service struct {
+		Count          int    `json:"count"`
+		EventFirstSeen string `json:"eventFirstSeen"`
+		EventLastSeen  string `json:"eventLastSeen"`
+	} `json:"service"`
+}
+
+// SecurityHubFindingsImported holds the detail of a Security Hub "Security Hub Findings - Imported"
+// EventBridge event, a batch of findings in the AWS Security Finding Format (ASFF)
+type SecurityHubFindingsImported struct {
+	Findings []SecurityHubFinding `json:"findings"`
+}
+
+// SecurityHubFinding holds a single ASFF finding
+type SecurityHubFinding struct {
+	SchemaVersion string   `json:"SchemaVersion"`
+	ID            string   `json:"Id"`
+	ProductArn    string   `json:"ProductArn"`
+	ProductName   string   `json:"ProductName"`
+	CompanyName   string   `json:"CompanyName"`
+	Region        string   `json:"Region"`
+	AWSAccountID  string   `json:"AwsAccountId"`
+	Types         []string `json:"Types"`
+	Title         string   `json:"Title"`
+	Description   string   `json:"Description"`
+	Severity      struct {
+		Label      string `json:"Label"`
+		Normalized int    `json:"Normalized"`
+	} `json:"Severity"`
+	Compliance struct {
+		Status string `json:"Status"`
+	} `json:"Compliance"`
+	Workflow struct {
+		Status string `json:"Status"`
+	} `json:"Workflow"`
+	Resources []struct {
+		Type   string `json:"Type"`
+		ID     string `json:"Id"`
+		Region string `json:"Region"`
+	} `json:"Resources"`
+}
+
+// HealthEvent holds the detail of an "AWS Health Event" EventBridge event
+type HealthEvent struct {
+	EventArn          string `json:"eventArn"`
+	Service           string `json:"service"`
+	EventTypeCode     string `json:"eventTypeCode"`
+	EventTypeCategory string `json:"eventTypeCategory"`
+	EventScopeCode    string `json:"eventScopeCode"`
+	StartTime         string `json:"startTime"`
+	EndTime           string `json:"endTime"`
+	LastUpdatedTime   string `json:"lastUpdatedTime"`
+	StatusCode        string `json:"statusCode"`
+	EventRegion       string `json:"eventRegion"`
+	AffectedAccount   string `json:"affectedAccount"`
+	EventDescription  []struct {
+		Language          string `json:"language"`
+		LatestDescription string `json:"latestDescription"`
+	} `json:"eventDescription"`
+	AffectedEntities []struct {
+		EntityValue string `json:"entityValue"`
+		Status      string `json:"status,omitempty"`
+	} `json:"affectedEntities"`
+}
+
+// CodePipelineStateChange holds the detail of a CodePipeline pipeline execution, stage or action
+// state change
+type CodePipelineStateChange struct {
+	Pipeline    string `json:"pipeline"`
+	ExecutionID string `json:"execution-id"`
+	Stage       string `json:"stage,omitempty"`
+	Action      string `json:"action,omitempty"`
+	State       string `json:"state"`
+	Type        *struct {
+		Owner    string `json:"owner"`
+		Category string `json:"category"`
+		Provider string `json:"provider"`
+	} `json:"type,omitempty"`
+}
+
+// CodeBuildStateChange holds the detail of a "CodeBuild Build State Change" event
+type CodeBuildStateChange struct {
+	BuildStatus           string `json:"build-status"`
+	ProjectName           string `json:"project-name"`
+	BuildID               string `json:"build-id"`
+	CurrentPhase          string `json:"current-phase"`
+	CurrentPhaseContext   string `json:"current-phase-context"`
+	AdditionalInformation struct {
+		Initiator   string `json:"initiator"`
+		BuildNumber int    `json:"build-number"`
+		Logs        struct {
+			GroupName  string `json:"group-name"`
+			StreamName string `json:"stream-name"`
+			DeepLink   string `json:"deep-link"`
+		} `json:"logs"`
+		Phases []struct {
+			PhaseType   string `json:"phase-type"`
+			PhaseStatus string `json:"phase-status"`
+		} `json:"phases"`
+	} `json:"additional-information"`
+}
+
+// CodeDeployStateChange holds the detail of a "CodeDeploy Deployment State-change Notification" event
+type CodeDeployStateChange struct {
+	Application     string `json:"application"`
+	DeploymentID    string `json:"deploymentId"`
+	DeploymentGroup string `json:"deploymentGroup"`
+	InstanceGroupID string `json:"instanceGroupId"`
+	State           string `json:"state"`
+	Region          string `json:"region"`
+}
+
+// ECSTaskStateChange holds the detail of an "ECS Task State Change" event
+type ECSTaskStateChange struct {
+	ClusterArn        string `json:"clusterArn"`
+	TaskArn           string `json:"taskArn"`
+	TaskDefinitionArn string `json:"taskDefinitionArn"`
+	Group             string `json:"group"`
+	LaunchType        string `json:"launchType"`
+	LastStatus        string `json:"lastStatus"`
+	DesiredStatus     string `json:"desiredStatus"`
+	StopCode          string `json:"stopCode,omitempty"`
+	StoppedReason     string `json:"stoppedReason,omitempty"`
+	Containers        []struct {
+		Name       string `json:"name"`
+		LastStatus string `json:"lastStatus"`
+		ExitCode   *int   `json:"exitCode,omitempty"`
+		Reason     string `json:"reason,omitempty"`
+	} `json:"containers"`
+}
+
+// ECSServiceAction holds the detail of an "ECS Service Action" event
+type ECSServiceAction struct {
+	EventType  string `json:"eventType"`
+	EventName  string `json:"eventName"`
+	ClusterArn string `json:"clusterArn"`
+	CreatedAt  string `json:"createdAt"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// SNSAutoScalingNotification holds the notification an EC2 Auto Scaling group sends to SNS for
+// instance launches and terminations, their failures, lifecycle hooks and tests
+type SNSAutoScalingNotification struct {
+	Service              string            `json:"Service"`
+	Event                string            `json:"Event"`
+	LifecycleTransition  string            `json:"LifecycleTransition,omitempty"`
+	LifecycleHookName    string            `json:"LifecycleHookName,omitempty"`
+	AccountID            string            `json:"AccountId"`
+	AutoScalingGroupName string            `json:"AutoScalingGroupName"`
+	AutoScalingGroupARN  string            `json:"AutoScalingGroupARN"`
+	ActivityID           string            `json:"ActivityId"`
+	Description          string            `json:"Description"`
+	Cause                string            `json:"Cause"`
+	StatusCode           string            `json:"StatusCode"`
+	StatusMessage        string            `json:"StatusMessage"`
+	StartTime            string            `json:"StartTime"`
+	EndTime              string            `json:"EndTime"`
+	Time                 string            `json:"Time"`
+	EC2InstanceID        string            `json:"EC2InstanceId"`
+	Details              map[string]string `json:"Details,omitempty"`
+}
+
+// SNSSesNotification holds an Amazon SES bounce, complaint or delivery notification. SES
+// notifications set NotificationType, SES event publishing sets EventType instead
+type SNSSesNotification struct {
+	NotificationType string `json:"notificationType"`
+	EventType        string `json:"eventType"`
+	Bounce           *struct {
+		BounceType        string `json:"bounceType"`
+		BounceSubType     string `json:"bounceSubType"`
+		BouncedRecipients []struct {
+			EmailAddress   string `json:"emailAddress"`
+			Action         string `json:"action"`
+			Status         string `json:"status"`
+			DiagnosticCode string `json:"diagnosticCode"`
+		} `json:"bouncedRecipients"`
+		Timestamp string `json:"timestamp"`
+	} `json:"bounce,omitempty"`
+	Complaint *struct {
+		ComplainedRecipients []struct {
+			EmailAddress string `json:"emailAddress"`
+		} `json:"complainedRecipients"`
+		ComplaintFeedbackType string `json:"complaintFeedbackType"`
+		Timestamp             string `json:"timestamp"`
+	} `json:"complaint,omitempty"`
+	Delivery *struct {
+		Recipients   []string `json:"recipients"`
+		SMTPResponse string   `json:"smtpResponse"`
+		Timestamp    string   `json:"timestamp"`
+	} `json:"delivery,omitempty"`
+	Mail struct {
+		MessageID   string   `json:"messageId"`
+		Source      string   `json:"source"`
+		SourceArn   string   `json:"sourceArn"`
+		Timestamp   string   `json:"timestamp"`
+		Destination []string `json:"destination"`
+	} `json:"mail"`
+}
+
+// SNSS3EventNotification holds an S3 event notification. Test events, sent when the notification
+// is configured, have no records and set Event to s3:TestEvent instead
+type SNSS3EventNotification struct {
+	Records []struct {
+		EventSource  string `json:"eventSource"`
+		EventName    string `json:"eventName"`
+		EventTime    string `json:"eventTime"`
+		AWSRegion    string `json:"awsRegion"`
+		UserIdentity struct {
+			PrincipalID string `json:"principalId"`
+		} `json:"userIdentity"`
+		RequestParameters struct {
+			SourceIPAddress string `json:"sourceIPAddress"`
+		} `json:"requestParameters"`
+		S3 struct {
+			Bucket struct {
+				Name string `json:"name"`
+				Arn  string `json:"arn"`
+			} `json:"bucket"`
+			Object struct {
+				Key       string `json:"key"`
+				Size      int64  `json:"size"`
+				ETag      string `json:"eTag"`
+				VersionID string `json:"versionId"`
+			} `json:"object"`
+		} `json:"s3"`
+	} `json:"Records"`
+
+	Service string `json:"Service"`
+	Event   string `json:"Event"`
+	Bucket  string `json:"Bucket"`
+	Time    string `json:"Time"`
+}
+
+// SNSBudgetNotification holds the fields of an AWS Budgets alert, which is sent as plain text
+type SNSBudgetNotification struct {
+	AccountID      string
+	BudgetName     string
+	BudgetType     string
+	BudgetedAmount string
+	AlertType      string
+	AlertThreshold string
+	// Amount is the actual or forecasted spend, depending on AlertType
+	Amount string
+}
+
+// SNSCostAnomalyNotification holds an AWS Cost Anomaly Detection alert
+type SNSCostAnomalyNotification struct {
+	AccountID          string `json:"accountId"`
+	AnomalyID          string `json:"anomalyId"`
+	AnomalyDetailsLink string `json:"anomalyDetailsLink"`
+	AnomalyStartDate   string `json:"anomalyStartDate"`
+	AnomalyEndDate     string `json:"anomalyEndDate"`
+	DimensionalValue   string `json:"dimensionalValue"`
+	MonitorArn         string `json:"monitorArn"`
+	MonitorName        string `json:"monitorName"`
+	Impact             struct {
+		MaxImpact             float64 `json:"maxImpact"`
+		TotalActualSpend      float64 `json:"totalActualSpend"`
+		TotalExpectedSpend    float64 `json:"totalExpectedSpend"`
+		TotalImpact           float64 `json:"totalImpact"`
+		TotalImpactPercentage float64 `json:"totalImpactPercentage"`
+	} `json:"impact"`
+	RootCauses []struct {
+		LinkedAccount     string `json:"linkedAccount"`
+		LinkedAccountName string `json:"linkedAccountName"`
+		Region            string `json:"region"`
+		Service           string `json:"service"`
+		UsageType         string `json:"usageType"`
+	} `json:"rootCauses"`
+}
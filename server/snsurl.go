@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/mattermost/mattermost-plugin-aws-SNS/server/arn"
+)
+
+// snsHostPattern matches the regional SNS endpoints of the aws, aws-cn and aws-us-gov
+// partitions, e.g. sns.us-east-1.amazonaws.com, sns.cn-north-1.amazonaws.com.cn and
+// sns.us-gov-west-1.amazonaws.com.
+var snsHostPattern = regexp.MustCompile(`^sns\.([a-z]{2}(?:-gov)?-[a-z]+-[0-9]+)\.amazonaws\.com(\.cn)?$`)
+
+// validateSNSURL makes sure a URL taken from an SNS message points to a genuine AWS SNS
+// endpoint before the plugin makes any request to it.
+func validateSNSURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q", rawURL)
+	}
+
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("URL %q must use https", rawURL)
+	}
+
+	if u.User != nil || u.Opaque != "" {
+		return nil, fmt.Errorf("URL %q is not a valid AWS SNS URL", rawURL)
+	}
+
+	if port := u.Port(); port != "" && port != "443" {
+		return nil, fmt.Errorf("URL %q uses an unexpected port", rawURL)
+	}
+
+	match := snsHostPattern.FindStringSubmatch(strings.ToLower(u.Hostname()))
+	if match == nil {
+		return nil, fmt.Errorf("host %q is not an AWS SNS endpoint", u.Hostname())
+	}
+
+	// China regions are only served from the amazonaws.com.cn domain and vice versa
+	isChinaRegion := strings.HasPrefix(match[1], "cn-")
+	if isChinaRegion != (match[2] != "") {
+		return nil, fmt.Errorf("host %q is not an AWS SNS endpoint", u.Hostname())
+	}
+
+	return u, nil
+}
+
+// validateSNSURLRegion checks that a URL validated by validateSNSURL points to the SNS endpoint
+// of the region of a topic, e.g. sns.us-east-1.amazonaws.com for a topic in us-east-1.
+func validateSNSURLRegion(rawURL string, topicArn arn.ARN) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q", rawURL)
+	}
+
+	host := "sns." + topicArn.Region + ".amazonaws.com"
+	if topicArn.Partition == "aws-cn" {
+		host += ".cn"
+	}
+	if !strings.EqualFold(u.Hostname(), host) {
+		return fmt.Errorf("host %q is not the AWS SNS endpoint of topic %s", u.Hostname(), topicArn.String())
+	}
+	return nil
+}
+
+// validateSigningCertURL checks that a SigningCertURL is an SNS hosted PEM certificate.
+func validateSigningCertURL(rawURL string) error {
+	u, err := validateSNSURL(rawURL)
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasSuffix(u.Path, ".pem") {
+		return fmt.Errorf("signing certificate URL %q does not reference a PEM file", rawURL)
+	}
+
+	return nil
+}
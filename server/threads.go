@@ -0,0 +1,217 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	eventThreadPrefix = "eventThread_"
+	// threadClaimAttempts is how often a post tries to find the root of a thread that another
+	// post is starting at the same time.
+	threadClaimAttempts = 3
+	// threadClaimTTL is how long a claimed thread waits for its root post. A claim left behind
+	// by a node that stopped while posting the root expires after it.
+	threadClaimTTL = 10 * time.Second
+)
+
+// threadClaimRetryDelay is the wait before looking again for a thread that another post is starting.
+var threadClaimRetryDelay = 100 * time.Millisecond
+
+// errThreadClaimed is returned by startThread when another post started the thread first.
+var errThreadClaimed = errors.New("the thread is being started by another post")
+
+// EventThread links the updates of an event posted to a channel to the post that started the thread.
+type EventThread struct {
+	RootPostID string
+}
+
+// eventThreadKey returns the KV store key of the thread of an event in a channel. kind separates
+// the identifiers of different event sources.
+func eventThreadKey(channelID, kind, eventID string) string {
+	sum := sha256.Sum256([]byte(kind + "/" + eventID + "/" + channelID))
+	return eventThreadPrefix + hex.EncodeToString(sum[:16])
+}
+
+func (p *Plugin) storeEventThread(key string, thread *EventThread, ttl time.Duration) error {
+	b, err := json.Marshal(thread)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal event thread")
+	}
+	if appErr := p.API.KVSetWithExpiry(key, b, int64(ttl.Seconds())); appErr != nil {
+		return appErr
+	}
+	return nil
+}
+
+// postThreaded posts the attachments as a reply in the thread stored under key, or starts a new
+// thread when there is none or its root post was deleted. It returns the root post of the thread
+// and whether the attachments were posted as a reply. Every post extends the thread by ttl.
+func (p *Plugin) postThreaded(channel *TeamChannel, key string, attachments []*model.SlackAttachment, ttl time.Duration) (*model.Post, bool, error) {
+	var root *model.Post
+	var isReply bool
+	err := p.inThread(key, &EventThread{},
+		func(threadRoot *model.Post, thread threadRecord) error {
+			if _, err := p.createBotPost(channel, threadRoot.Id, attachments); err != nil {
+				return err
+			}
+			root, isReply = threadRoot, true
+			return p.storeEventThread(key, thread.(*EventThread), ttl)
+		},
+		func(stored []byte) error {
+			var err error
+			root, err = p.startThread(key, stored, p.newBotPost(channel, "", attachments), ttl, func(rootPostID string) interface{} {
+				return &EventThread{RootPostID: rootPostID}
+			})
+			return err
+		},
+	)
+	if err == errThreadClaimed {
+		p.API.LogWarn("AWSSNS unable to find the event thread, posting the event on its own", "key", key)
+		root, err = p.createBotPost(channel, "", attachments)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return root, isReply, nil
+}
+
+// threadRecord is a thread stored in the KV store.
+type threadRecord interface {
+	rootPostID() string
+}
+
+func (t *EventThread) rootPostID() string {
+	return t.RootPostID
+}
+
+// inThread calls reply with the root post of the thread stored under key, or start with the
+// stored value when there is no thread or its root post was deleted. When start returns
+// errThreadClaimed, another post started the thread and it is looked up again; errThreadClaimed
+// is returned when it could not be found after threadClaimAttempts.
+func (p *Plugin) inThread(key string, thread threadRecord, reply func(root *model.Post, thread threadRecord) error, start func(stored []byte) error) error {
+	for attempt := 0; attempt < threadClaimAttempts; attempt++ {
+		stored, err := p.getThread(key, thread)
+		if err != nil {
+			return err
+		}
+
+		if stored != nil {
+			if root := p.threadRoot(thread.rootPostID()); root != nil {
+				return reply(root, thread)
+			}
+			if thread.rootPostID() == "" {
+				// another post is starting the thread
+				time.Sleep(threadClaimRetryDelay)
+				continue
+			}
+			p.API.LogDebug("AWSSNS thread is gone, starting a new one", "post_id", thread.rootPostID())
+		}
+
+		if err = start(stored); err != errThreadClaimed {
+			return err
+		}
+	}
+	return errThreadClaimed
+}
+
+// getThread decodes the thread stored under key into thread. It returns the stored value, which
+// is nil when there is no thread.
+func (p *Plugin) getThread(key string, thread interface{}) ([]byte, error) {
+	val, appErr := p.API.KVGet(key)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if val == nil {
+		return nil, nil
+	}
+	if err := json.Unmarshal(val, thread); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal thread")
+	}
+	return val, nil
+}
+
+// threadRoot returns the root post of a thread, or nil when it was deleted.
+func (p *Plugin) threadRoot(rootPostID string) *model.Post {
+	if rootPostID == "" {
+		return nil
+	}
+	root, appErr := p.API.GetPost(rootPostID)
+	if appErr != nil || root.DeleteAt != 0 {
+		return nil
+	}
+	return root
+}
+
+// newBotPost returns a post of the attachments to the channel by the bot, a reply when rootID is set.
+func (p *Plugin) newBotPost(channel *TeamChannel, rootID string, attachments []*model.SlackAttachment) *model.Post {
+	post := &model.Post{
+		ChannelId: channel.ChannelID,
+		UserId:    p.BotUserID,
+		RootId:    rootID,
+	}
+	model.ParseSlackAttachment(post, attachments)
+	return post
+}
+
+// createBotPost posts the attachments to the channel as the bot, as a reply when rootID is set.
+func (p *Plugin) createBotPost(channel *TeamChannel, rootID string, attachments []*model.SlackAttachment) (*model.Post, error) {
+	created, appErr := p.API.CreatePost(p.newBotPost(channel, rootID, attachments))
+	if appErr != nil {
+		return nil, appErr
+	}
+	return created, nil
+}
+
+// startThread posts root as the root of a new thread stored under key. stored is the
+// value found under key, nil or a thread whose root post is gone. The key is claimed atomically
+// before posting, so concurrent posts don't start two threads; errThreadClaimed is returned when
+// another post claimed it first. The claim expires after threadClaimTTL and is released when the
+// thread can't be started. newThread builds the stored thread for a root post id.
+func (p *Plugin) startThread(key string, stored []byte, root *model.Post, ttl time.Duration, newThread func(rootPostID string) interface{}) (*model.Post, error) {
+	claim, err := json.Marshal(newThread(""))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal thread")
+	}
+	saved, appErr := p.API.KVSetWithOptions(key, claim, model.PluginKVSetOptions{
+		Atomic:          true,
+		OldValue:        stored,
+		ExpireInSeconds: int64(threadClaimTTL.Seconds()),
+	})
+	if appErr != nil {
+		return nil, appErr
+	}
+	if !saved {
+		return nil, errThreadClaimed
+	}
+
+	created, appErr := p.API.CreatePost(root)
+	if appErr != nil {
+		p.releaseThread(key, claim)
+		return nil, appErr
+	}
+
+	b, err := json.Marshal(newThread(created.Id))
+	if err != nil {
+		p.releaseThread(key, claim)
+		return nil, errors.Wrap(err, "failed to marshal thread")
+	}
+	if appErr = p.API.KVSetWithExpiry(key, b, int64(ttl.Seconds())); appErr != nil {
+		p.releaseThread(key, claim)
+		return nil, appErr
+	}
+	return created, nil
+}
+
+// releaseThread removes the claim of a thread that could not be started, so the next post
+// starts it instead of waiting for the claim to expire.
+func (p *Plugin) releaseThread(key string, claim []byte) {
+	if _, appErr := p.API.KVCompareAndDelete(key, claim); appErr != nil {
+		p.API.LogWarn("AWSSNS unable to release the thread", "err", appErr.Error())
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-plugin-aws-SNS/server/arn"
+)
+
+const (
+	webhookTokenPrefix = "webhookToken_"
+	webhookTokenLength = 32
+)
+
+// WebhookToken holds the secret used to authenticate AWS SNS requests for a channel, or for a
+// single topic of a channel. After a rotation the previous token is still accepted until
+// PreviousExpiresAt, so existing subscriptions can be moved to the new URL.
+type WebhookToken struct {
+	Token             string
+	PreviousToken     string
+	PreviousExpiresAt int64
+}
+
+// accepts compares token in constant time against the current and, while the overlap window is
+// open, the previous token.
+func (t *WebhookToken) accepts(token string) bool {
+	if t == nil || token == "" {
+		return false
+	}
+
+	if secureCompare(token, t.Token) {
+		return true
+	}
+
+	return t.PreviousToken != "" &&
+		model.GetMillis() < t.PreviousExpiresAt &&
+		secureCompare(token, t.PreviousToken)
+}
+
+func secureCompare(given, expected string) bool {
+	if expected == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(given), []byte(expected)) == 1
+}
+
+// webhookTokenKey returns the KV store key of a channel token, or of a topic token when topic
+// is set. Topics are hashed to stay within the KV key length limit.
+func webhookTokenKey(channelID, topic string) string {
+	if topic == "" {
+		return webhookTokenPrefix + channelID
+	}
+	sum := sha256.Sum256([]byte(topic))
+	return webhookTokenPrefix + channelID + "_" + hex.EncodeToString(sum[:16])
+}
+
+func (p *Plugin) getWebhookToken(channelID, topic string) (*WebhookToken, error) {
+	val, appErr := p.API.KVGet(webhookTokenKey(channelID, topic))
+	if appErr != nil {
+		return nil, appErr
+	}
+	if val == nil {
+		return nil, nil
+	}
+
+	var token WebhookToken
+	if err := json.Unmarshal(val, &token); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal webhook token")
+	}
+	return &token, nil
+}
+
+func (p *Plugin) storeWebhookToken(channelID, topic string, token *WebhookToken) error {
+	b, err := json.Marshal(token)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal webhook token")
+	}
+	if appErr := p.API.KVSet(webhookTokenKey(channelID, topic), b); appErr != nil {
+		return appErr
+	}
+	return nil
+}
+
+// getOrCreateWebhookToken returns the token of a channel or topic, generating one on first use.
+func (p *Plugin) getOrCreateWebhookToken(channelID, topic string) (*WebhookToken, error) {
+	token, err := p.getWebhookToken(channelID, topic)
+	if err != nil || token != nil {
+		return token, err
+	}
+
+	token = &WebhookToken{Token: model.NewRandomString(webhookTokenLength)}
+	if err := p.storeWebhookToken(channelID, topic, token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// rotateWebhookToken generates a new token for a channel or topic. The replaced token keeps
+// working for the configured overlap window.
+func (p *Plugin) rotateWebhookToken(channelID, topic string) (*WebhookToken, error) {
+	token, err := p.getWebhookToken(channelID, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	rotated := &WebhookToken{Token: model.NewRandomString(webhookTokenLength)}
+	overlap := time.Duration(p.getConfiguration().TokenRotationOverlapHours) * time.Hour
+	if token != nil && overlap > 0 {
+		rotated.PreviousToken = token.Token
+		rotated.PreviousExpiresAt = model.GetMillis() + overlap.Milliseconds()
+	}
+
+	if err := p.storeWebhookToken(channelID, topic, rotated); err != nil {
+		return nil, err
+	}
+	return rotated, nil
+}
+
+// checkToken authenticates a request for the given channel. Requests carrying a topic parameter
+// must use the token of that topic, all others the channel token or, if still enabled, the
+// global token from the plugin configuration.
+func (p *Plugin) checkToken(r *http.Request, channel *TeamChannel) error {
+	query := r.URL.Query()
+	token := query.Get("token")
+	if token == "" {
+		return fmt.Errorf("invalid or missing token")
+	}
+
+	if topic := query.Get("topic"); topic != "" {
+		topicToken, err := p.getWebhookToken(channel.ChannelID, topic)
+		if err != nil {
+			return errors.Wrap(err, "failed to get topic token")
+		}
+		if !topicToken.accepts(token) {
+			return fmt.Errorf("invalid or missing token")
+		}
+		return nil
+	}
+
+	channelToken, err := p.getWebhookToken(channel.ChannelID, "")
+	if err != nil {
+		return errors.Wrap(err, "failed to get channel token")
+	}
+	if channelToken.accepts(token) {
+		return nil
+	}
+
+	config := p.getConfiguration()
+	if config.AcceptGlobalToken && secureCompare(token, config.Token) {
+		return nil
+	}
+
+	return fmt.Errorf("invalid or missing token")
+}
+
+// topicTokenMatches reports whether a message of the topic ARN may use the token of topic. Topic
+// tokens are issued for a topic ARN; tokens issued for a topic name before match the topics of
+// that name.
+func topicTokenMatches(topic, topicArn string) bool {
+	if arn.IsARN(topic) {
+		return topic == topicArn
+	}
+	parsed, err := parseTopicArn(topicArn)
+	return err == nil && parsed.Resource == topic
+}
+
+// subscriptionURL builds the URL to use for an AWS SNS HTTPS subscription.
+func (p *Plugin) subscriptionURL(channel *TeamChannel, topic, token string) string {
+	query := url.Values{}
+	query.Set("token", token)
+	query.Set("channel", channel.NameString())
+	if topic != "" {
+		query.Set("topic", topic)
+	}
+	return fmt.Sprintf("%s/plugins/%s?%s", *p.API.GetConfig().ServiceSettings.SiteURL, manifest.Id, query.Encode())
+}
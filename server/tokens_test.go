@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckToken(t *testing.T) {
+	channel := &TeamChannel{TeamName: "team1", ChannelName: "channel1", ChannelID: "channelId1"}
+
+	marshal := func(token *WebhookToken) []byte {
+		b, err := json.Marshal(token)
+		require.NoError(t, err)
+		return b
+	}
+
+	channelToken := marshal(&WebhookToken{
+		Token:             "channel-token",
+		PreviousToken:     "rotated-token",
+		PreviousExpiresAt: model.GetMillis() + 60*1000,
+	})
+	topicToken := marshal(&WebhookToken{
+		Token:             "topic-token",
+		PreviousToken:     "expired-token",
+		PreviousExpiresAt: model.GetMillis() - 60*1000,
+	})
+
+	for name, test := range map[string]struct {
+		Query             string
+		AcceptGlobalToken bool
+		ShouldError       bool
+	}{
+		"Channel token":                     {Query: "token=channel-token"},
+		"Previous channel token in overlap": {Query: "token=rotated-token"},
+		"Topic token":                       {Query: "token=topic-token&topic=alarms"},
+		"Expired previous topic token":      {Query: "token=expired-token&topic=alarms", ShouldError: true},
+		"Channel token used for a topic":    {Query: "token=channel-token&topic=alarms", ShouldError: true},
+		"Topic token used for the channel":  {Query: "token=topic-token", ShouldError: true},
+		"Global token":                      {Query: "token=global-token", AcceptGlobalToken: true},
+		"Global token no longer accepted":   {Query: "token=global-token", ShouldError: true},
+		"Missing token":                     {Query: "", AcceptGlobalToken: true, ShouldError: true},
+		"Topic without a token of its own":  {Query: "token=topic-token&topic=other", ShouldError: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			api := &plugintest.API{}
+			api.On("KVGet", webhookTokenKey("channelId1", "")).Return(channelToken, nil)
+			api.On("KVGet", webhookTokenKey("channelId1", "alarms")).Return(topicToken, nil)
+			api.On("KVGet", webhookTokenKey("channelId1", "other")).Return(nil, nil)
+
+			p := Plugin{}
+			p.SetAPI(api)
+			p.setConfiguration(&configuration{Token: "global-token", AcceptGlobalToken: test.AcceptGlobalToken})
+
+			err := p.checkToken(httptest.NewRequest("POST", "/?"+test.Query, nil), channel)
+			if test.ShouldError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestServeHTTPAuthentication(t *testing.T) {
+	channelToken, err := json.Marshal(&WebhookToken{Token: "channel-token"})
+	require.NoError(t, err)
+
+	var responses []string
+	for _, query := range []string{
+		"channel=team1,unknown&token=channel-token",
+		"channel=team1,channel1&token=wrong-token",
+	} {
+		api := &plugintest.API{}
+		api.On("KVGet", webhookTokenKey("channelId1", "")).Return(channelToken, nil)
+		api.On("LogError", mock.Anything, mock.Anything, mock.Anything).Return()
+
+		p := Plugin{Channels: []*TeamChannel{{TeamName: "team1", ChannelName: "channel1", ChannelID: "channelId1"}}}
+		p.SetAPI(api)
+		p.setConfiguration(&configuration{})
+
+		w := httptest.NewRecorder()
+		p.ServeHTTP(nil, w, httptest.NewRequest("POST", "/?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		responses = append(responses, w.Body.String())
+	}
+	assert.Equal(t, responses[0], responses[1])
+}
+
+func TestTopicTokenMatches(t *testing.T) {
+	const topicArn = "arn:aws:sns:us-east-1:123456789012:alerts"
+
+	assert.True(t, topicTokenMatches(topicArn, topicArn))
+	assert.False(t, topicTokenMatches(topicArn, "arn:aws:sns:us-east-1:210987654321:alerts"))
+	assert.False(t, topicTokenMatches(topicArn, "arn:aws:sns:eu-west-1:123456789012:alerts"))
+	assert.True(t, topicTokenMatches("alerts", topicArn))
+	assert.False(t, topicTokenMatches("alarms", topicArn))
+}
@@ -1,6 +1,407 @@
 package main
 
-// SNSTopics A struct to hold the Topics subscribed by the channel
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-plugin-aws-SNS/server/arn"
+)
+
+const (
+	topicsListPrefix        = "topicsInChannel_"
+	subscriptionStatsPrefix = "topicStats_"
+)
+
+// errTopicsUnchanged stops an update of the topics of a channel that has nothing to change.
+var errTopicsUnchanged = errors.New("topics are unchanged")
+
+// SNSTopics holds the SNS subscriptions of a channel, keyed by topic ARN. Topics holds the
+// entries written before subscriptions had records; they are keyed by topic ARN, or by topic
+// name for the oldest ones, and are moved to Subscriptions on activation.
 type SNSTopics struct {
-	Topics map[string]bool
+	Subscriptions map[string]*Subscription `json:"Subscriptions,omitempty"`
+	Topics        map[string]bool          `json:"Topics,omitempty"`
+
+	// migrated is set when Topics were moved to Subscriptions since the topics were read.
+	migrated bool
+}
+
+// Subscription records an SNS subscription of a channel. Times are in milliseconds.
+type Subscription struct {
+	TopicArn        string
+	SubscriptionArn string `json:",omitempty"`
+	UnsubscribeURL  string `json:",omitempty"`
+	ConfirmedBy     string `json:",omitempty"`
+	ConfirmedAt     int64  `json:",omitempty"`
+	Paused          bool
+}
+
+// SubscriptionStats counts the notifications received by the subscription of a channel to a
+// topic. They are kept apart from the subscriptions of the channel, so notifications don't
+// rewrite those.
+type SubscriptionStats struct {
+	LastMessageAt int64
+	MessageCount  int64
+}
+
+// confirmSubscriptionResponse holds the answer of AWS SNS to a SubscribeURL request.
+type confirmSubscriptionResponse struct {
+	SubscriptionArn string `xml:"ConfirmSubscriptionResult>SubscriptionArn"`
+}
+
+// parseTopicArn parses arn:partition:sns:region:account-id:topic-name.
+func parseTopicArn(value string) (arn.ARN, error) {
+	topicArn, err := arn.Parse(value)
+	if err != nil {
+		return arn.ARN{}, err
+	}
+	if topicArn.Service != "sns" || topicArn.Region == "" || topicArn.AccountID == "" || strings.ContainsAny(topicArn.Resource, ":/") {
+		return arn.ARN{}, errors.Errorf("%q is not an SNS topic ARN", value)
+	}
+	return topicArn, nil
+}
+
+// parseConfirmSubscriptionResponse returns the subscription ARN AWS SNS sent back when a
+// subscription was confirmed.
+func parseConfirmSubscriptionResponse(body io.Reader) (string, error) {
+	var response confirmSubscriptionResponse
+	if err := xml.NewDecoder(body).Decode(&response); err != nil {
+		return "", errors.Wrap(err, "failed to decode the confirmation response")
+	}
+	return response.SubscriptionArn, nil
+}
+
+// migrate moves the topics stored before subscriptions had records to Subscriptions.
+func (t *SNSTopics) migrate() {
+	if len(t.Topics) == 0 {
+		return
+	}
+	if t.Subscriptions == nil {
+		t.Subscriptions = map[string]*Subscription{}
+	}
+	for key := range t.Topics {
+		if _, ok := t.Subscriptions[key]; !ok {
+			t.Subscriptions[key] = &Subscription{TopicArn: key}
+		}
+	}
+	t.Topics = nil
+	t.migrated = true
+}
+
+// find returns the key of the subscription matching a topic ARN or, when it is unique, a topic
+// name. Entries stored under a topic name before topics were keyed by ARN are only matched by
+// name when no topic ARN has that name.
+func (t *SNSTopics) find(topic string) (string, error) {
+	if arn.IsARN(topic) {
+		if _, ok := t.Subscriptions[topic]; ok {
+			return topic, nil
+		}
+		return "", errors.Errorf("the channel is not subscribed to the topic %s", topic)
+	}
+
+	var keys []string
+	for key := range t.Subscriptions {
+		if topicArn, err := parseTopicArn(key); err == nil && topicArn.Resource == topic {
+			keys = append(keys, key)
+		}
+	}
+	switch len(keys) {
+	case 0:
+		if _, ok := t.Subscriptions[topic]; ok {
+			return topic, nil
+		}
+		return "", errors.Errorf("the channel is not subscribed to the topic %s", topic)
+	case 1:
+		return keys[0], nil
+	default:
+		return "", errors.Errorf("the channel is subscribed to several topics named %s, use the topic ARN", topic)
+	}
+}
+
+// adoptLegacy merges the entry stored under the name of a topic before topics were keyed by ARN
+// into the subscription of the topic ARN.
+func (t *SNSTopics) adoptLegacy(topicArn arn.ARN) {
+	legacy := t.Subscriptions[topicArn.Resource]
+	if legacy == nil {
+		return
+	}
+	delete(t.Subscriptions, topicArn.Resource)
+
+	subscription := t.Subscriptions[topicArn.String()]
+	if subscription == nil {
+		legacy.TopicArn = topicArn.String()
+		t.Subscriptions[topicArn.String()] = legacy
+		return
+	}
+
+	if subscription.SubscriptionArn == "" {
+		subscription.SubscriptionArn = legacy.SubscriptionArn
+	}
+	if subscription.UnsubscribeURL == "" {
+		subscription.UnsubscribeURL = legacy.UnsubscribeURL
+	}
+	if subscription.ConfirmedBy == "" {
+		subscription.ConfirmedBy = legacy.ConfirmedBy
+		subscription.ConfirmedAt = legacy.ConfirmedAt
+	}
+	subscription.Paused = subscription.Paused || legacy.Paused
+}
+
+// getTopics returns the subscriptions of a channel, migrating entries that were not migrated yet.
+func (p *Plugin) getTopics(channelID string) (*SNSTopics, error) {
+	val, appErr := p.API.KVGet(topicsListPrefix + channelID)
+	if appErr != nil {
+		return nil, errors.Wrap(appErr, "failed to get the topics of the channel")
+	}
+	return decodeTopics(val)
+}
+
+func decodeTopics(val []byte) (*SNSTopics, error) {
+	topics := &SNSTopics{}
+	if val != nil {
+		if err := json.Unmarshal(val, topics); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal the topics of the channel")
+		}
+	}
+	topics.migrate()
+	if topics.Subscriptions == nil {
+		topics.Subscriptions = map[string]*Subscription{}
+	}
+	return topics, nil
+}
+
+// modifyTopics applies modify to the subscriptions of a channel with an atomic update. When
+// another update of the channel gets in between, modify runs again on the new subscriptions.
+// Returning errTopicsUnchanged from modify skips the update.
+func (p *Plugin) modifyTopics(channelID string, modify func(*SNSTopics) error) error {
+	var modifyErr error
+	err := p.client.KV.SetAtomicWithRetries(topicsListPrefix+channelID, func(oldValue []byte) (interface{}, error) {
+		topics, err := decodeTopics(oldValue)
+		if err != nil {
+			return nil, err
+		}
+		if modifyErr = modify(topics); modifyErr != nil {
+			return nil, modifyErr
+		}
+		return topics, nil
+	})
+	if modifyErr == errTopicsUnchanged {
+		return nil
+	}
+	if modifyErr != nil {
+		return modifyErr
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to update the topics of the channel")
+	}
+	return nil
+}
+
+// updateKVStore applies modify to the subscription of the channel to a topic, creating the
+// subscription when it is missing.
+func (p *Plugin) updateKVStore(channelID, topicArn string, modify func(*Subscription)) (*Subscription, error) {
+	var updated Subscription
+	err := p.modifyTopics(channelID, func(topics *SNSTopics) error {
+		subscription := topics.Subscriptions[topicArn]
+		if subscription == nil {
+			subscription = &Subscription{TopicArn: topicArn}
+			topics.Subscriptions[topicArn] = subscription
+		}
+		modify(subscription)
+		updated = *subscription
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// deleteFromKVStore removes a topic from the subscriptions of the channel, including an entry
+// stored under its name before topics were keyed by ARN.
+func (p *Plugin) deleteFromKVStore(topicArn arn.ARN, channelID string) error {
+	err := p.modifyTopics(channelID, func(topics *SNSTopics) error {
+		delete(topics.Subscriptions, topicArn.String())
+		delete(topics.Subscriptions, topicArn.Resource)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if appErr := p.API.KVDelete(subscriptionStatsKey(channelID, topicArn.String())); appErr != nil {
+		return errors.Wrap(appErr, "failed to delete the message counts of the topic")
+	}
+	return nil
+}
+
+// confirmSubscription records who confirmed the subscription of the channel to a topic.
+func (p *Plugin) confirmSubscription(channelID string, topicArn arn.ARN, subscriptionArn, userID string) error {
+	_, err := p.updateKVStore(channelID, topicArn.String(), func(subscription *Subscription) {
+		if subscriptionArn != "" {
+			subscription.SubscriptionArn = subscriptionArn
+		}
+		subscription.ConfirmedBy = userID
+		subscription.ConfirmedAt = model.GetMillis()
+		subscription.Paused = false
+	})
+	return err
+}
+
+// recordSubscriptionMessage returns the subscription of a channel to the topic of a notification
+// and counts the notification. The subscriptions of the channel are only written when the
+// subscription has no record yet, as for subscriptions confirmed outside of Mattermost, when an
+// entry stored under the topic name is merged into it, or when its UnsubscribeURL changed.
+func (p *Plugin) recordSubscriptionMessage(channelID string, envelope *snsEnvelope) (*Subscription, error) {
+	topicArn, err := parseTopicArn(envelope.TopicArn)
+	if err != nil {
+		return nil, err
+	}
+
+	topics, err := p.getTopics(channelID)
+	if err != nil {
+		return nil, err
+	}
+	subscription := topics.Subscriptions[topicArn.String()]
+	if subscription == nil || topics.Subscriptions[topicArn.Resource] != nil ||
+		(envelope.UnsubscribeURL != "" && envelope.UnsubscribeURL != subscription.UnsubscribeURL) {
+		if subscription, err = p.updateSubscriptionRecord(channelID, topicArn, envelope.UnsubscribeURL); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err = p.countSubscriptionMessage(channelID, topicArn.String()); err != nil {
+		p.API.LogWarn("AWSSNS unable to count the message of the subscription", "topic_arn", topicArn.String(), "err", err.Error())
+	}
+	return subscription, nil
+}
+
+// updateSubscriptionRecord creates the record of the subscription of a channel to a topic,
+// merging an entry stored under the topic name, and keeps its UnsubscribeURL.
+func (p *Plugin) updateSubscriptionRecord(channelID string, topicArn arn.ARN, unsubscribeURL string) (*Subscription, error) {
+	var updated Subscription
+	err := p.modifyTopics(channelID, func(topics *SNSTopics) error {
+		topics.adoptLegacy(topicArn)
+		subscription := topics.Subscriptions[topicArn.String()]
+		if subscription == nil {
+			subscription = &Subscription{TopicArn: topicArn.String()}
+			topics.Subscriptions[topicArn.String()] = subscription
+		}
+
+		if unsubscribeURL != "" {
+			subscription.UnsubscribeURL = unsubscribeURL
+			if query, err := url.Parse(unsubscribeURL); err == nil && query.Query().Get("SubscriptionArn") != "" {
+				subscription.SubscriptionArn = query.Query().Get("SubscriptionArn")
+			}
+		}
+		updated = *subscription
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// subscriptionStatsKey returns the KV store key of the message counts of the subscription of a
+// channel to a topic.
+func subscriptionStatsKey(channelID, topicArn string) string {
+	sum := sha256.Sum256([]byte(channelID + "/" + topicArn))
+	return subscriptionStatsPrefix + hex.EncodeToString(sum[:16])
+}
+
+// countSubscriptionMessage counts a notification of the subscription of a channel to a topic.
+func (p *Plugin) countSubscriptionMessage(channelID, topicArn string) (*SubscriptionStats, error) {
+	var stats SubscriptionStats
+	err := p.client.KV.SetAtomicWithRetries(subscriptionStatsKey(channelID, topicArn), func(oldValue []byte) (interface{}, error) {
+		stats = SubscriptionStats{}
+		if oldValue != nil {
+			if err := json.Unmarshal(oldValue, &stats); err != nil {
+				return nil, errors.Wrap(err, "failed to unmarshal the message counts")
+			}
+		}
+		stats.LastMessageAt = model.GetMillis()
+		stats.MessageCount++
+		return &stats, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+func (p *Plugin) getSubscriptionStats(channelID, topicArn string) (*SubscriptionStats, error) {
+	stats := &SubscriptionStats{}
+	if err := p.client.KV.Get(subscriptionStatsKey(channelID, topicArn), stats); err != nil {
+		return nil, errors.Wrap(err, "failed to get the message counts")
+	}
+	return stats, nil
+}
+
+// setSubscriptionPaused pauses or resumes the subscription of the channel to a topic, given by
+// ARN or name. It returns the ARN of the topic.
+func (p *Plugin) setSubscriptionPaused(channelID, topic string, paused bool) (string, error) {
+	var key string
+	err := p.modifyTopics(channelID, func(topics *SNSTopics) error {
+		var err error
+		if key, err = topics.find(topic); err != nil {
+			return err
+		}
+		topics.Subscriptions[key].Paused = paused
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// migrateTopics moves the topic lists of every channel to subscription records.
+func (p *Plugin) migrateTopics() error {
+	const perPage = 100
+	for page := 0; ; page++ {
+		keys, appErr := p.API.KVList(page, perPage)
+		if appErr != nil {
+			return errors.Wrap(appErr, "failed to list the KV store keys")
+		}
+
+		for _, key := range keys {
+			if !strings.HasPrefix(key, topicsListPrefix) {
+				continue
+			}
+			if err := p.migrateChannelTopics(strings.TrimPrefix(key, topicsListPrefix)); err != nil {
+				return err
+			}
+		}
+
+		if len(keys) < perPage {
+			return nil
+		}
+	}
+}
+
+func (p *Plugin) migrateChannelTopics(channelID string) error {
+	var count int
+	err := p.modifyTopics(channelID, func(topics *SNSTopics) error {
+		// decoding already migrated the topics, they only need to be stored
+		if !topics.migrated {
+			return errTopicsUnchanged
+		}
+		count = len(topics.Subscriptions)
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to migrate the topics of channel %s", channelID)
+	}
+	if count > 0 {
+		p.API.LogInfo("AWSSNS migrated the topics of a channel to subscription records", "channel_id", channelID, "count", count)
+	}
+	return nil
 }
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTopicArn(t *testing.T) {
+	topicArn, err := parseTopicArn("arn:aws-us-gov:sns:us-gov-east-1:123456789012:alerts")
+	require.NoError(t, err)
+	assert.Equal(t, "alerts", topicArn.Resource)
+	assert.Equal(t, "us-gov-east-1", topicArn.Region)
+
+	for _, value := range []string{
+		"",
+		"alerts",
+		"arn:aws:sqs:us-east-1:123456789012:alerts",
+		"arn:aws:sns:us-east-1::alerts",
+		"arn:aws:sns:us-east-1:123456789012:alerts:subscription-id",
+	} {
+		_, err := parseTopicArn(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestParseConfirmSubscriptionResponse(t *testing.T) {
+	subscriptionArn, err := parseConfirmSubscriptionResponse(strings.NewReader(`<ConfirmSubscriptionResponse xmlns="http://sns.amazonaws.com/doc/2010-03-31/">
+  <ConfirmSubscriptionResult>
+    <SubscriptionArn>arn:aws:sns:us-east-1:123456789012:alerts:2bcfbf39-05c3-41de-beaa-fcfcc21c8f55</SubscriptionArn>
+  </ConfirmSubscriptionResult>
+  <ResponseMetadata>
+    <RequestId>075ecce8-8dac-11e1-bf80-f781d96e9307</RequestId>
+  </ResponseMetadata>
+</ConfirmSubscriptionResponse>`))
+	require.NoError(t, err)
+	assert.Equal(t, "arn:aws:sns:us-east-1:123456789012:alerts:2bcfbf39-05c3-41de-beaa-fcfcc21c8f55", subscriptionArn)
+}
+
+// expectTopicsUpdate mocks an atomic update of the topics of channelId1 from stored to topics
+// that match.
+func expectTopicsUpdate(api *plugintest.API, stored []byte, match func(*SNSTopics) bool) {
+	api.On("KVGet", topicsListPrefix+"channelId1").Return(stored, nil).Once()
+	api.On("KVSetWithOptions", topicsListPrefix+"channelId1", mock.MatchedBy(func(b []byte) bool {
+		var topics SNSTopics
+		return json.Unmarshal(b, &topics) == nil && match(&topics)
+	}), model.PluginKVSetOptions{Atomic: true, OldValue: stored}).Return(true, nil).Once()
+}
+
+// expectCount mocks counting a notification of a topic in channelId1 on top of stored counts.
+func expectCount(api *plugintest.API, topicArn string, stored []byte, count int64) {
+	key := subscriptionStatsKey("channelId1", topicArn)
+	api.On("KVGet", key).Return(stored, nil).Once()
+	api.On("KVSetWithOptions", key, mock.MatchedBy(func(b []byte) bool {
+		var stats SubscriptionStats
+		return json.Unmarshal(b, &stats) == nil && stats.MessageCount == count && stats.LastMessageAt > 0
+	}), model.PluginKVSetOptions{Atomic: true, OldValue: stored}).Return(true, nil).Once()
+}
+
+func marshalTopics(t *testing.T, topics *SNSTopics) []byte {
+	b, err := json.Marshal(topics)
+	require.NoError(t, err)
+	return b
+}
+
+func TestSubscriptionRecords(t *testing.T) {
+	east, err := parseTopicArn("arn:aws:sns:us-east-1:123456789012:alerts")
+	require.NoError(t, err)
+	west, err := parseTopicArn("arn:aws:sns:us-west-2:123456789012:alerts")
+	require.NoError(t, err)
+
+	newPlugin := func(api *plugintest.API) *Plugin {
+		p := &Plugin{}
+		p.SetAPI(api)
+		p.client = pluginapi.NewClient(api, &plugintest.Driver{})
+		return p
+	}
+
+	t.Run("Confirming keeps topics with the same name apart", func(t *testing.T) {
+		api := &plugintest.API{}
+		expectTopicsUpdate(api, marshalTopics(t, &SNSTopics{Topics: map[string]bool{west.String(): true}}), func(topics *SNSTopics) bool {
+			return topics.Topics == nil && len(topics.Subscriptions) == 2 &&
+				topics.Subscriptions[west.String()].TopicArn == west.String() &&
+				topics.Subscriptions[east.String()].ConfirmedBy == "userId1" &&
+				topics.Subscriptions[east.String()].ConfirmedAt > 0 &&
+				topics.Subscriptions[east.String()].SubscriptionArn == east.String()+":uuid"
+		})
+		defer api.AssertExpectations(t)
+
+		require.NoError(t, newPlugin(api).confirmSubscription("channelId1", east, east.String()+":uuid", "userId1"))
+	})
+
+	t.Run("Notifications are counted without writing the subscription", func(t *testing.T) {
+		unsubscribeURL := "https://sns.us-east-1.amazonaws.com/?Action=Unsubscribe&SubscriptionArn=" + east.String() + ":uuid"
+		stats, err := json.Marshal(&SubscriptionStats{MessageCount: 41})
+		require.NoError(t, err)
+
+		api := &plugintest.API{}
+		api.On("KVGet", topicsListPrefix+"channelId1").Return(marshalTopics(t, &SNSTopics{Subscriptions: map[string]*Subscription{
+			east.String(): {TopicArn: east.String(), UnsubscribeURL: unsubscribeURL, Paused: true},
+		}}), nil).Once()
+		expectCount(api, east.String(), stats, 42)
+		defer api.AssertExpectations(t)
+
+		subscription, err := newPlugin(api).recordSubscriptionMessage("channelId1", &snsEnvelope{TopicArn: east.String(), UnsubscribeURL: unsubscribeURL})
+		require.NoError(t, err)
+		assert.True(t, subscription.Paused)
+		api.AssertNotCalled(t, "KVSetWithOptions", topicsListPrefix+"channelId1", mock.Anything, mock.Anything)
+	})
+
+	t.Run("First notification records the subscription", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("KVGet", topicsListPrefix+"channelId1").Return(nil, nil).Once()
+		expectTopicsUpdate(api, nil, func(topics *SNSTopics) bool {
+			return topics.Subscriptions[east.String()].SubscriptionArn == east.String()+":uuid"
+		})
+		expectCount(api, east.String(), nil, 1)
+		defer api.AssertExpectations(t)
+
+		subscription, err := newPlugin(api).recordSubscriptionMessage("channelId1", &snsEnvelope{
+			TopicArn:       east.String(),
+			UnsubscribeURL: "https://sns.us-east-1.amazonaws.com/?Action=Unsubscribe&SubscriptionArn=" + east.String() + ":uuid",
+		})
+		require.NoError(t, err)
+		assert.False(t, subscription.Paused)
+	})
+
+	t.Run("Unsubscribing removes the topic and its legacy name entry", func(t *testing.T) {
+		api := &plugintest.API{}
+		expectTopicsUpdate(api, marshalTopics(t, &SNSTopics{Topics: map[string]bool{west.String(): true, "alerts": true}}), func(topics *SNSTopics) bool {
+			return len(topics.Subscriptions) == 0 && len(topics.Topics) == 0
+		})
+		api.On("KVDelete", subscriptionStatsKey("channelId1", west.String())).Return(nil)
+		defer api.AssertExpectations(t)
+
+		require.NoError(t, newPlugin(api).deleteFromKVStore(west, "channelId1"))
+	})
+
+	t.Run("Pausing by name needs a unique topic", func(t *testing.T) {
+		stored := marshalTopics(t, &SNSTopics{Subscriptions: map[string]*Subscription{
+			east.String(): {TopicArn: east.String()},
+			west.String(): {TopicArn: west.String()},
+		}})
+
+		api := &plugintest.API{}
+		api.On("KVGet", topicsListPrefix+"channelId1").Return(stored, nil).Once()
+		expectTopicsUpdate(api, stored, func(topics *SNSTopics) bool {
+			return topics.Subscriptions[west.String()].Paused && !topics.Subscriptions[east.String()].Paused
+		})
+		defer api.AssertExpectations(t)
+
+		p := newPlugin(api)
+		_, err := p.setSubscriptionPaused("channelId1", "alerts", true)
+		assert.EqualError(t, err, "the channel is subscribed to several topics named alerts, use the topic ARN")
+
+		topicArn, err := p.setSubscriptionPaused("channelId1", west.String(), true)
+		require.NoError(t, err)
+		assert.Equal(t, west.String(), topicArn)
+	})
+
+	t.Run("Pausing by name after the migration", func(t *testing.T) {
+		// the entry of the oldest topic list only has the name of the topic
+		legacy := marshalTopics(t, &SNSTopics{Topics: map[string]bool{"alerts": true}})
+		paused := marshalTopics(t, &SNSTopics{Subscriptions: map[string]*Subscription{"alerts": {TopicArn: "alerts", Paused: true}}})
+
+		api := &plugintest.API{}
+		expectTopicsUpdate(api, legacy, func(topics *SNSTopics) bool {
+			return len(topics.Subscriptions) == 1 && topics.Subscriptions["alerts"].Paused
+		})
+		expectTopicsUpdate(api, paused, func(topics *SNSTopics) bool {
+			return len(topics.Subscriptions) == 1 &&
+				topics.Subscriptions[east.String()].Paused &&
+				topics.Subscriptions[east.String()].TopicArn == east.String()
+		})
+		api.On("KVGet", topicsListPrefix+"channelId1").Return(paused, nil).Once()
+		expectCount(api, east.String(), nil, 1)
+		defer api.AssertExpectations(t)
+
+		p := newPlugin(api)
+		topicArn, err := p.setSubscriptionPaused("channelId1", "alerts", true)
+		require.NoError(t, err)
+		assert.Equal(t, "alerts", topicArn)
+
+		subscription, err := p.recordSubscriptionMessage("channelId1", &snsEnvelope{TopicArn: east.String()})
+		require.NoError(t, err)
+		assert.True(t, subscription.Paused)
+	})
+
+	t.Run("Notification merges the legacy entry into the topic ARN", func(t *testing.T) {
+		stored := marshalTopics(t, &SNSTopics{Subscriptions: map[string]*Subscription{
+			"alerts":      {TopicArn: "alerts", ConfirmedBy: "userId1", Paused: true},
+			east.String(): {TopicArn: east.String()},
+		}})
+
+		api := &plugintest.API{}
+		api.On("KVGet", topicsListPrefix+"channelId1").Return(stored, nil).Once()
+		expectTopicsUpdate(api, stored, func(topics *SNSTopics) bool {
+			subscription := topics.Subscriptions[east.String()]
+			return len(topics.Subscriptions) == 1 && subscription.Paused && subscription.ConfirmedBy == "userId1"
+		})
+		expectCount(api, east.String(), nil, 1)
+		defer api.AssertExpectations(t)
+
+		subscription, err := newPlugin(api).recordSubscriptionMessage("channelId1", &snsEnvelope{TopicArn: east.String()})
+		require.NoError(t, err)
+		assert.True(t, subscription.Paused)
+	})
+
+	t.Run("Pausing by name prefers the topic ARN over its legacy entry", func(t *testing.T) {
+		stored := marshalTopics(t, &SNSTopics{Subscriptions: map[string]*Subscription{
+			"alerts":      {TopicArn: "alerts"},
+			east.String(): {TopicArn: east.String()},
+		}})
+
+		api := &plugintest.API{}
+		expectTopicsUpdate(api, stored, func(topics *SNSTopics) bool {
+			return topics.Subscriptions[east.String()].Paused && !topics.Subscriptions["alerts"].Paused
+		})
+		defer api.AssertExpectations(t)
+
+		topicArn, err := newPlugin(api).setSubscriptionPaused("channelId1", "alerts", true)
+		require.NoError(t, err)
+		assert.Equal(t, east.String(), topicArn)
+	})
+
+	t.Run("Interleaved updates are retried", func(t *testing.T) {
+		// another confirmation stores the west topic between the read and the write of this one
+		before := marshalTopics(t, &SNSTopics{})
+		after := marshalTopics(t, &SNSTopics{Subscriptions: map[string]*Subscription{west.String(): {TopicArn: west.String()}}})
+
+		api := &plugintest.API{}
+		api.On("KVGet", topicsListPrefix+"channelId1").Return(before, nil).Once()
+		api.On("KVSetWithOptions", topicsListPrefix+"channelId1", mock.Anything, model.PluginKVSetOptions{Atomic: true, OldValue: before}).Return(false, nil).Once()
+		expectTopicsUpdate(api, after, func(topics *SNSTopics) bool {
+			return len(topics.Subscriptions) == 2 && topics.Subscriptions[west.String()] != nil && topics.Subscriptions[east.String()].ConfirmedBy == "userId1"
+		})
+		defer api.AssertExpectations(t)
+
+		require.NoError(t, newPlugin(api).confirmSubscription("channelId1", east, "", "userId1"))
+	})
+
+	t.Run("Interleaved notifications are all counted", func(t *testing.T) {
+		key := subscriptionStatsKey("channelId1", east.String())
+		stored := func(count int64) []byte {
+			b, err := json.Marshal(&SubscriptionStats{MessageCount: count})
+			require.NoError(t, err)
+			return b
+		}
+
+		// both notifications read a count of 1, the second one loses and counts again on top of 2
+		api := &plugintest.API{}
+		api.On("KVGet", topicsListPrefix+"channelId1").Return(marshalTopics(t, &SNSTopics{Subscriptions: map[string]*Subscription{
+			east.String(): {TopicArn: east.String()},
+		}}), nil).Twice()
+		api.On("KVGet", key).Return(stored(1), nil).Twice()
+		api.On("KVSetWithOptions", key, mock.Anything, model.PluginKVSetOptions{Atomic: true, OldValue: stored(1)}).Return(true, nil).Once()
+		api.On("KVSetWithOptions", key, mock.Anything, model.PluginKVSetOptions{Atomic: true, OldValue: stored(1)}).Return(false, nil).Once()
+		expectCount(api, east.String(), stored(2), 3)
+		defer api.AssertExpectations(t)
+
+		p := newPlugin(api)
+		var wg sync.WaitGroup
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := p.recordSubscriptionMessage("channelId1", &snsEnvelope{TopicArn: east.String()})
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+	})
+
+	t.Run("Unreadable subscriptions are returned as errors", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("KVGet", topicsListPrefix+"channelId1").Return(nil, model.NewAppError("KVGet", "app.plugin.kv.get", nil, "", 500))
+		defer api.AssertExpectations(t)
+
+		_, err := newPlugin(api).recordSubscriptionMessage("channelId1", &snsEnvelope{TopicArn: east.String()})
+		assert.Error(t, err)
+	})
+
+	t.Run("KV errors are returned", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("KVGet", topicsListPrefix+"channelId1").Return(nil, model.NewAppError("KVGet", "app.plugin.kv.get", nil, "", 500))
+		defer api.AssertExpectations(t)
+
+		assert.Error(t, newPlugin(api).deleteFromKVStore(west, "channelId1"))
+	})
+}
+
+func TestMigrateTopics(t *testing.T) {
+	legacy := marshalTopics(t, &SNSTopics{Topics: map[string]bool{"alerts": true, "arn:aws:sns:us-east-1:123456789012:billing": true}})
+	migrated := marshalTopics(t, &SNSTopics{Subscriptions: map[string]*Subscription{"alerts": {TopicArn: "alerts"}}})
+
+	api := &plugintest.API{}
+	api.On("KVList", 0, 100).Return([]string{topicsListPrefix + "channelId1", topicsListPrefix + "channelId2", "messageId_abc"}, nil)
+	expectTopicsUpdate(api, legacy, func(topics *SNSTopics) bool {
+		return topics.Topics == nil && len(topics.Subscriptions) == 2 &&
+			topics.Subscriptions["alerts"].TopicArn == "alerts" &&
+			topics.Subscriptions["arn:aws:sns:us-east-1:123456789012:billing"].TopicArn == "arn:aws:sns:us-east-1:123456789012:billing"
+	})
+	api.On("KVGet", topicsListPrefix+"channelId2").Return(migrated, nil)
+	api.On("LogInfo", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return().Once()
+	defer api.AssertExpectations(t)
+
+	p := Plugin{}
+	p.SetAPI(api)
+	p.client = pluginapi.NewClient(api, &plugintest.Driver{})
+	require.NoError(t, p.migrateTopics())
+	api.AssertNotCalled(t, "KVSetWithOptions", topicsListPrefix+"channelId2", mock.Anything, mock.Anything)
+}